@@ -0,0 +1,12 @@
+// Command upboundctl manages applications in an upbound registry from the command line.
+package main
+
+import (
+	"os"
+
+	"github.com/alexeldeib/upbound/pkg/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -1,25 +1,249 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/alexeldeib/upbound/pkg/admission"
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/certs"
+	"github.com/alexeldeib/upbound/pkg/config"
 	"github.com/alexeldeib/upbound/pkg/handlers"
+	"github.com/alexeldeib/upbound/pkg/policy"
+	"github.com/alexeldeib/upbound/pkg/ratelimit"
+	"github.com/alexeldeib/upbound/pkg/tracing"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func main() {
-	log.SetOutput(os.Stdout)
-	log.SetLevel(log.InfoLevel)
+	cfg, opts, err := config.Load(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.PrintConfig {
+		data, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Stdout.Write(data)
+		return
+	}
+	if err := cfg.SetupLogging(); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		File:        cfg.Tracing.File,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	server := handlers.NewServer()
+	server.Auth.AnonymousRead = cfg.Auth.AnonymousRead
+	server.MaxBodyBytes = cfg.Limits.MaxBodyBytes
+	if err := server.TrustProxies(cfg.TrustedProxies...); err != nil {
+		log.Fatal(err)
+	}
+	switch cfg.Audit.File {
+	case "":
+		log.Warn("audit.file is not set, the audit log is lost on restart.")
+	case "-":
+		server.AuditLog = audit.NewWriter(os.Stdout)
+	default:
+		if server.AuditLog, err = audit.Open(cfg.Audit.File); err != nil {
+			log.Fatal(err)
+		}
+	}
+	server.Limiter = ratelimit.New(
+		ratelimit.Limit{Rate: cfg.Limits.ReadRate, Burst: cfg.Limits.ReadBurst},
+		ratelimit.Limit{Rate: cfg.Limits.WriteRate, Burst: cfg.Limits.WriteBurst},
+		ratelimit.Limit{Rate: cfg.Limits.AuthFailureRate, Burst: cfg.Limits.AuthFailureBurst},
+	)
+
+	// Tokens only live in memory, so the first admin token has to come from the configuration on every start.
+	if cfg.Auth.AdminToken != "" {
+		server.Auth.Tokens.Add(cfg.Auth.AdminToken, "bootstrap-admin", "", []string{auth.Admin}, 100*365*24*time.Hour)
+	} else {
+		log.Warn("UPBOUND_ADMIN_TOKEN is not set, no tokens can be issued.")
+	}
+
+	if cfg.Auth.JWKS != "" {
+		verifier, err := auth.NewJWTVerifier(cfg.Auth.JWKS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		verifier.Issuer = cfg.Auth.JWTIssuer
+		verifier.Audience = cfg.Auth.JWTAudience
+		verifier.EmailClaim = cfg.Auth.JWTEmailClaim
+		verifier.GroupsClaim = cfg.Auth.JWTGroupsClaim
+		verifier.TrustUnverifiedEmail = cfg.Auth.JWTTrustUnverifiedEmail
+		verifier.Scopes = cfg.Auth.JWTScopes
+		verifier.GroupScopes = make(map[string][]string)
+		for _, group := range cfg.Auth.JWTWriteGroups {
+			verifier.GroupScopes[group] = append(verifier.GroupScopes[group], auth.Write)
+		}
+		for _, group := range cfg.Auth.JWTAdminGroups {
+			verifier.GroupScopes[group] = append(verifier.GroupScopes[group], auth.Admin)
+		}
+		server.Auth.JWT = verifier
+		server.Health.Register("jwks", verifier.Check)
+	}
+
+	if cfg.Admission.DefaultLicense != "" {
+		server.Admission.AddMutator("default-license", admission.DefaultLicense(cfg.Admission.DefaultLicense))
+	}
+	// Reviews echo the application back, so they are bounded like the requests submitting it.
+	webhook := func(url string) *admission.Webhook {
+		hook := admission.NewWebhook(url)
+		if cfg.Limits.MaxBodyBytes > 0 {
+			hook.MaxReviewBytes = cfg.Limits.MaxBodyBytes
+		}
+		return hook
+	}
+	for _, url := range cfg.Admission.MutatingWebhooks {
+		server.Admission.AddMutator(url, webhook(url))
+	}
+	for _, url := range cfg.Admission.ValidatingWebhooks {
+		server.Admission.AddValidator(url, webhook(url))
+	}
+	if cfg.Admission.Policy != "" {
+		p, err := policy.Load(cfg.Admission.Policy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.Policy = p
+	}
 
-	http.HandleFunc("/create", server.Create)
-	http.HandleFunc("/search", server.Search)
+	httpServer := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
+	// Watch streams only end when told to, so end them as soon as shutdown starts rather than waiting them out.
+	httpServer.RegisterOnShutdown(server.Webhooks.EndWatches)
+	if cfg.TLS.Cert != "" {
+		reloader, err := certs.NewReloader(cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		httpServer.TLSConfig, err = certs.ServerConfig(reloader, cfg.TLS.ClientCA, cfg.TLS.RequireClientCert)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if cfg.TLS.ClientCA != "" {
+			server.Auth.ClientCertScopes = cfg.TLS.ClientCertScopes
+		}
+	}
+
+	// Cloned before serving, which sets up HTTP/2 by changing the HTTP server's NextProtos.
+	var grpcTLSConfig *tls.Config
+	if httpServer.TLSConfig != nil {
+		grpcTLSConfig = httpServer.TLSConfig.Clone()
+	}
 
-	log.Info("Starting up the server.")
+	log.WithFields(log.Fields{"address": cfg.Listen, "tls": httpServer.TLSConfig != nil}).Info("Starting up the server.")
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatalf(err.Error())
+	served := make(chan error, 2)
+	go func() {
+		if httpServer.TLSConfig != nil {
+			// Certificates come from TLSConfig.GetCertificate, so no files are passed here.
+			served <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			served <- httpServer.ListenAndServe()
+		}
+	}()
+
+	// The gRPC API shares the server's state and TLS configuration, but needs its own port since it isn't routed by path.
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Listen != "" {
+		grpcServer = server.GRPC(grpcTLSConfig)
+		listener, err := net.Listen("tcp", cfg.GRPC.Listen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.WithFields(log.Fields{"address": cfg.GRPC.Listen, "tls": grpcTLSConfig != nil}).Info("Serving gRPC.")
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				served <- err
+			}
+		}()
+	}
+
+	// Profiles are served unauthenticated, on their own listener so it can be kept off the public interface.
+	var debugServer *http.Server
+	if cfg.Debug.Listen != "" {
+		debugServer = &http.Server{Addr: cfg.Debug.Listen, Handler: handlers.Pprof(), ReadHeaderTimeout: cfg.Timeouts.ReadHeader}
+		log.WithFields(log.Fields{"address": cfg.Debug.Listen}).Info("Serving pprof.")
+		go func() {
+			if err := debugServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.WithFields(log.Fields{"error": err}).Error("Failed to serve pprof")
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-served:
+		log.Fatal(err)
+	case sig := <-signals:
+		log.WithFields(log.Fields{"signal": sig.String(), "delay": cfg.Timeouts.DrainDelay.String()}).Info("Shutting down, failing readiness checks.")
+	}
+
+	// Keep serving while endpoints controllers and load balancers notice the failing readiness and move traffic away.
+	server.Health.Drain()
+	time.Sleep(cfg.Timeouts.DrainDelay)
+	log.WithFields(log.Fields{"timeout": cfg.Timeouts.Shutdown.String()}).Info("Draining in-flight requests.")
+
+	// Stop accepting connections and wait for handlers, then for the work they left behind, within one deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Failed to drain in-flight requests")
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			log.Error("Failed to drain in-flight gRPC calls")
+			grpcServer.Stop()
+		}
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Failed to drain webhook deliveries or close the audit log")
+	}
+	if debugServer != nil {
+		debugServer.Close()
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.WithFields(log.Fields{"error": err}).Error("Failed to flush traces")
 	}
+	log.Info("Shut down.")
 }
@@ -1,19 +1,68 @@
 package main_test
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	stdlog "log"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
-
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/admission"
+	upboundv1 "github.com/alexeldeib/upbound/pkg/api/upbound/v1"
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/certs"
+	"github.com/alexeldeib/upbound/pkg/cli"
+	"github.com/alexeldeib/upbound/pkg/client"
+	"github.com/alexeldeib/upbound/pkg/config"
 	"github.com/alexeldeib/upbound/pkg/handlers"
+	"github.com/alexeldeib/upbound/pkg/health"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/metrics"
+	"github.com/alexeldeib/upbound/pkg/openapi"
+	"github.com/alexeldeib/upbound/pkg/policy"
+	"github.com/alexeldeib/upbound/pkg/ratelimit"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	"github.com/alexeldeib/upbound/pkg/tracing"
 	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	yamlv2 "gopkg.in/yaml.v2"
 )
 
 var server handlers.Server
@@ -21,6 +70,7 @@ var server handlers.Server
 func TestMain(m *testing.M) {
 	server = handlers.NewServer()
 	logrus.SetOutput(ioutil.Discard)
+	logging.Access.SetOutput(ioutil.Discard)
 	m.Run()
 }
 
@@ -382,6 +432,2225 @@ description: |
 	cleanup()
 }
 
+func TestUpdateAndDelete(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	owner := &auth.Identity{Subject: "owner", Email: "firstmaintainer@hotmail.com", Scopes: []string{auth.Write}}
+
+	// Updating an unknown application should fail
+	rr := execute(yaml, "PUT", "/update", as(owner, server.Update), t)
+	equals(t, http.StatusNotFound, rr.Code)
+	equals(t, "No application with title Valid App 1 exists.", rr.Body.String())
+
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	rr = execute(strings.Replace(yaml, "0.0.1", "0.0.2", 1), "PUT", "/update", as(owner, server.Update), t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, "0.0.2", server.Applications[0].Version)
+
+	rr = execute("", "DELETE", "/delete?title=Valid+App+1", as(owner, server.Delete), t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, 0, len(server.Applications))
+
+	rr = execute("", "DELETE", "/delete?title=Valid+App+1", as(owner, server.Delete), t)
+	equals(t, http.StatusNotFound, rr.Code)
+
+	cleanup()
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	type received struct {
+		event, signature string
+		body             []byte
+	}
+	deliveries := make(chan received, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		deliveries <- received{r.Header.Get(webhooks.EventHeader), r.Header.Get(webhooks.SignatureHeader), body}
+	}))
+	defer receiver.Close()
+
+	subscription := fmt.Sprintf("url: %s\nsecret: s3cr3t\nevents: [ application.created, application.deleted ]", receiver.URL)
+	rr := execute(subscription, "PUT", "/webhooks", server.WebhookSubscriptions, t)
+	equals(t, http.StatusCreated, rr.Code)
+	assert(t, !strings.Contains(rr.Body.String(), "s3cr3t"), "secret leaked in response: %s", rr.Body.String())
+
+	owner := &auth.Identity{Subject: "owner", Email: "firstmaintainer@hotmail.com", Scopes: []string{auth.Write}}
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	rr = execute(strings.Replace(yaml, "0.0.1", "0.0.2", 1), "PUT", "/update", as(owner, server.Update), t)
+	server.Webhooks.Wait()
+
+	// Only the create event is subscribed to, the update should be skipped.
+	equals(t, 1, len(deliveries))
+	got := <-deliveries
+	equals(t, webhooks.Created, got.event)
+	equals(t, webhooks.Sign("s3cr3t", got.body), got.signature)
+	assert(t, strings.Contains(string(got.body), "title: Valid App 1"), "unexpected payload: %s", got.body)
+
+	rr = execute("", "GET", "/webhooks/deliveries", server.WebhookDeliveries, t)
+	equals(t, http.StatusOK, rr.Code)
+	assert(t, strings.Contains(rr.Body.String(), "succeeded: true"), "unexpected history: %s", rr.Body.String())
+
+	cleanup()
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	server.Webhooks.MaxAttempts = 3
+	server.Webhooks.Backoff = time.Millisecond
+	rr := execute(fmt.Sprintf("url: %s\nsecret: s3cr3t", receiver.URL), "PUT", "/webhooks", server.WebhookSubscriptions, t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	server.Webhooks.Wait()
+
+	equals(t, 3, attempts)
+	equals(t, 3, len(server.Webhooks.History("")))
+	deadLetters := server.Webhooks.DeadLetters()
+	equals(t, 1, len(deadLetters))
+	equals(t, "receiver responded with status 503", deadLetters[0].LastError)
+
+	cleanup()
+}
+
+func TestShutdownDrainsWebhooks(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	// A retry an hour away must not hold up shutdown.
+	server.Webhooks.Backoff = time.Hour
+	rr := execute(fmt.Sprintf("url: %s\nsecret: s3cr3t", receiver.URL), "PUT", "/webhooks", server.WebhookSubscriptions, t)
+	equals(t, http.StatusCreated, rr.Code)
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok(t, server.Shutdown(ctx))
+	deadLetters := server.Webhooks.DeadLetters()
+	equals(t, 1, len(deadLetters))
+	equals(t, 1, deadLetters[0].Attempts)
+	equals(t, "dispatcher closed before retrying, last error: receiver responded with status 503", deadLetters[0].LastError)
+
+	// Events after shutdown are dropped rather than delivered.
+	rr = execute(strings.Replace(yaml, "Valid App 1", "Valid App 2", 1), "PUT", "/create", server.Create, t)
+	equals(t, http.StatusCreated, rr.Code)
+	server.Webhooks.Wait()
+	equals(t, 1, len(server.Webhooks.History("")))
+}
+
+func TestAdmissionMutatorInjectsLicense(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+description: A really cool app.`
+
+	server.Admission.AddMutator("default-license", admission.DefaultLicense("Apache-2.0"))
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+
+	equals(t, http.StatusCreated, rr.Code)
+	equals(t, "Apache-2.0", server.Applications[0].License)
+
+	cleanup()
+}
+
+func TestAdmissionValidatorRejects(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	server.Admission.AddValidator("two-maintainers", admission.ValidatorFunc(func(app *types.ApplicationMetadata) error {
+		if len(app.Maintainers) < 2 {
+			return fmt.Errorf("at least two maintainers are required")
+		}
+		return nil
+	}))
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Rejected by admission hook two-maintainers: at least two maintainers are required\n", rr.Body.String())
+	equals(t, 0, len(server.Applications))
+
+	cleanup()
+}
+
+func TestAdmissionWebhook(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: GPL-3.0
+description: A really cool app.`
+
+	// Patch the company of every app, and refuse copyleft licenses.
+	mutating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("allowed: true\npatch:\n  company: Patched Inc."))
+	}))
+	defer mutating.Close()
+	validating := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "GPL") {
+			w.Write([]byte("allowed: false\nreason: copyleft licenses are not approved"))
+			return
+		}
+		w.Write([]byte("allowed: true"))
+	}))
+	defer validating.Close()
+
+	server.Admission.AddMutator("patch-company", admission.NewWebhook(mutating.URL))
+	server.Admission.AddValidator("license-check", admission.NewWebhook(validating.URL))
+
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Rejected by admission hook license-check: copyleft licenses are not approved\n", rr.Body.String())
+
+	rr = execute(strings.Replace(yaml, "GPL-3.0", "MIT", 1), "PUT", "/create", server.Create, t)
+	equals(t, http.StatusCreated, rr.Code)
+	equals(t, "Patched Inc.", server.Applications[0].Company)
+
+	// An unreachable endpoint fails closed.
+	validating.Close()
+	rr = execute(strings.Replace(yaml, "Valid App 1", "Valid App 2", 1), "PUT", "/create", server.Create, t)
+	equals(t, http.StatusInternalServerError, rr.Code)
+
+	// So does one responding with more than a review's worth of data, which is never read in full.
+	cleanup()
+	flooding := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("allowed: true\nreason: "))
+		w.Write(bytes.Repeat([]byte("x"), 2*admission.DefaultMaxReviewBytes))
+	}))
+	defer flooding.Close()
+	server.Admission.AddValidator("flooding", admission.NewWebhook(flooding.URL))
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusInternalServerError, rr.Code)
+	assert(t, strings.Contains(rr.Body.String(), "review is larger than 1048576 bytes"), "expected an oversized review to be refused, got %q", rr.Body.String())
+	equals(t, 0, len(server.Applications))
+
+	// Hooks are called within the request, so they give up when the caller goes away rather than at their timeout.
+	cleanup()
+	stuck := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stuck
+	}))
+	defer hanging.Close()
+	defer close(stuck)
+	server.Admission.AddValidator("hanging", admission.NewWebhook(hanging.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "PUT", "/create", strings.NewReader(yaml))
+	ok(t, err)
+	rr = httptest.NewRecorder()
+	start := time.Now()
+	server.Create(rr, req)
+	equals(t, http.StatusInternalServerError, rr.Code)
+	assert(t, time.Since(start) < 5*time.Second, "expected the hook to be cancelled with the request, took %s", time.Since(start))
+
+	cleanup()
+}
+
+func TestPolicyRejection(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Other Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: GPL-3.0
+description: A really cool app.`
+
+	p, err := policy.New([]policy.Rule{
+		{Name: "approved-license", Expression: "License in ['Apache-2.0', 'MIT'] || Company == 'Random Inc.'", Message: "License must be approved unless published by Random Inc."},
+		{Name: "two-maintainers", Expression: "Maintainers.filter(m, m.Email.endsWith('@hotmail.com')).size() >= 2", Message: "At least two maintainers must use hotmail.com"},
+		{Name: "has-title", Expression: "Title != ''", Message: "Title is required"},
+	})
+	ok(t, err)
+	server.Policy = p
+
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	expected := "Failed to satisfy the following policies:\napproved-license: License must be approved unless published by Random Inc.\ntwo-maintainers: At least two maintainers must use hotmail.com\n"
+	equals(t, expected, rr.Body.String())
+
+	// The dry run reports every rule without persisting.
+	rr = execute(strings.Replace(yaml, "Other Inc.", "Random Inc.", 1), "POST", "/policies/test", server.PolicyTest, t)
+	equals(t, http.StatusOK, rr.Code)
+	expected = `- rule: approved-license
+  passed: true
+- rule: two-maintainers
+  passed: false
+  message: At least two maintainers must use hotmail.com
+- rule: has-title
+  passed: true
+`
+	equals(t, expected, rr.Body.String())
+	equals(t, 0, len(server.Applications))
+
+	cleanup()
+}
+
+func TestPolicyCompileErrors(t *testing.T) {
+	_, err := policy.New([]policy.Rule{{Name: "not-bool", Expression: "Title", Message: "oops"}})
+	assert(t, err != nil, "expected non-bool rule to fail compilation")
+
+	_, err = policy.New([]policy.Rule{{Name: "unknown-field", Expression: "Licence == 'MIT'", Message: "oops"}})
+	assert(t, err != nil, "expected unknown variable to fail compilation")
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+
+	// Without credentials, writes and reads are refused.
+	rr := authorized(yaml, "PUT", "/create", "", t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	rr = authorized("", "POST", "/search", "", t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+
+	// Issue a read-only token, which can search but not create.
+	rr = authorized("name: reader\nscopes: [ read ]\nttl: 1h", "PUT", "/tokens", "admin-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+	issued := struct {
+		Token string
+		ID    string
+	}{}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &issued))
+	assert(t, strings.HasPrefix(issued.Token, "upb_"), "unexpected token %s", issued.Token)
+
+	rr = authorized("", "POST", "/search", issued.Token, t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized(yaml, "PUT", "/create", issued.Token, t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized(yaml, "PUT", "/create", "admin-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	// The secret is never listed, and revoking the token locks it out.
+	rr = authorized("", "GET", "/tokens", "admin-secret", t)
+	assert(t, !strings.Contains(rr.Body.String(), issued.Token), "token secret leaked in listing")
+	rr = authorized("", "DELETE", "/tokens?id="+issued.ID, "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized("", "POST", "/search", issued.Token, t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+
+	cleanup()
+}
+
+func TestTokenExpiryAndAnonymousRead(t *testing.T) {
+	server.Auth.Tokens.Add("expired-secret", "expired", "", []string{auth.Write}, -time.Minute)
+	server.Auth.AnonymousRead = true
+
+	rr := authorized("", "POST", "/search", "", t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized("", "PUT", "/create", "", t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	rr = authorized("", "PUT", "/create", "expired-secret", t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	equals(t, "Authentication failed: bearer token has expired\n", rr.Body.String())
+
+	cleanup()
+}
+
+func TestOnlyMaintainersModify(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("stranger-secret", "stranger", "stranger@gmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+
+	rr := authorized(yaml, "PUT", "/create", "owner-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	rr = authorized(yaml, "PUT", "/update", "stranger-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	equals(t, "Only maintainers of Valid App 1 or admins may modify it.\n", rr.Body.String())
+	rr = authorized("", "DELETE", "/delete?title=Valid+App+1", "stranger-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+
+	// Owners cannot sneak in new maintainers without a transfer, admins can.
+	added := strings.Replace(yaml, "maintainers:\n", "maintainers:\n- name: stranger\n  email: stranger@gmail.com\n", 1)
+	rr = authorized(added, "PUT", "/update", "owner-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	equals(t, "Adding maintainer stranger@gmail.com requires a transfer accepted by them.\n", rr.Body.String())
+	rr = authorized(added, "PUT", "/update", "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	cleanup()
+}
+
+func TestOwnershipTransfer(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("newcomer-secret", "newcomer", "newcomer@gmail.com", []string{auth.Write}, time.Hour)
+	transfer := `title: Valid App 1
+to:
+  name: newcomer
+  email: newcomer@gmail.com`
+
+	rr := authorized(yaml, "PUT", "/create", "owner-secret", t)
+
+	// Strangers can't give away someone else's application.
+	rr = authorized(transfer, "PUT", "/transfers", "newcomer-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+
+	rr = authorized(transfer, "PUT", "/transfers", "owner-secret", t)
+	equals(t, http.StatusAccepted, rr.Code)
+	proposed := types.Transfer{}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &proposed))
+	equals(t, "firstmaintainer@hotmail.com", proposed.From)
+
+	// The proposer can't accept on the newcomer's behalf.
+	rr = authorized("", "PUT", "/transfers/accept?id="+proposed.ID, "owner-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	equals(t, "firstmaintainer@hotmail.com", server.Applications[0].Maintainers[0].Email)
+
+	rr = authorized("", "PUT", "/transfers/accept?id="+proposed.ID, "newcomer-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, 1, len(server.Applications[0].Maintainers))
+	equals(t, "newcomer@gmail.com", server.Applications[0].Maintainers[0].Email)
+	equals(t, 0, len(server.Transfers))
+
+	// The previous owner has lost access.
+	rr = authorized("", "DELETE", "/delete?title=Valid+App+1", "owner-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+
+	cleanup()
+}
+
+func TestTransferReviewed(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+- name: secondmaintainer app1
+  email: secondmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	p, err := policy.New([]policy.Rule{
+		{Name: "hotmail-maintainers", Expression: "Maintainers.all(m, m.Email.endsWith('@hotmail.com'))", Message: "Maintainers must use hotmail.com"},
+	})
+	ok(t, err)
+	server.Policy = p
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("newcomer-secret", "newcomer", "newcomer@gmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("second-secret", "second", "secondmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	equals(t, http.StatusCreated, authorized(yaml, "PUT", "/create", "owner-secret", t).Code)
+
+	// Accepting runs the same checks as an update, and a refused transfer stays pending.
+	rr := authorized("title: Valid App 1\nto:\n  name: newcomer\n  email: newcomer@gmail.com", "PUT", "/transfers", "owner-secret", t)
+	equals(t, http.StatusAccepted, rr.Code)
+	proposed := types.Transfer{}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &proposed))
+	rr = authorized("", "PUT", "/transfers/accept?id="+proposed.ID, "newcomer-secret", t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to satisfy the following policies:\nhotmail-maintainers: Maintainers must use hotmail.com\n", rr.Body.String())
+	equals(t, "firstmaintainer@hotmail.com", server.Applications[0].Maintainers[0].Email)
+	equals(t, 1, len(server.Transfers))
+
+	// Handing over to a co-maintainer doesn't list them twice.
+	rr = authorized("title: Valid App 1\nto:\n  name: secondmaintainer app1\n  email: secondmaintainer@hotmail.com", "PUT", "/transfers", "owner-secret", t)
+	equals(t, http.StatusAccepted, rr.Code)
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &proposed))
+	rr = authorized("", "PUT", "/transfers/accept?id="+proposed.ID, "second-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, 1, len(server.Applications[0].Maintainers))
+	equals(t, "secondmaintainer@hotmail.com", server.Applications[0].Maintainers[0].Email)
+}
+
+func TestCompanyAdminRoleBinding(t *testing.T) {
+	random := `title: Random App
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	other := strings.Replace(strings.Replace(random, "Random App", "Other App", 1), "Random Inc.", "Other Inc.", 1)
+
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("lead-secret", "lead", "lead@random.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("viewer-secret", "viewer", "viewer@random.com", []string{auth.Read}, time.Hour)
+
+	rr := authorized(random, "PUT", "/create", "owner-secret", t)
+	rr = authorized(other, "PUT", "/create", "owner-secret", t)
+	equals(t, 2, len(server.Applications))
+
+	// Only global admins manage bindings, and company-admin bindings need a company.
+	binding := "role: company-admin\ncompany: Random Inc.\nusers: [ lead@random.com ]"
+	rr = authorized(binding, "PUT", "/rolebindings", "lead-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized("role: company-admin\nusers: [ lead@random.com ]", "PUT", "/rolebindings", "admin-secret", t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	rr = authorized(binding, "PUT", "/rolebindings", "admin-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	// The company admin manages their company's apps, but not other companies' or moving apps between companies.
+	rr = authorized(strings.Replace(random, "0.0.1", "0.0.2", 1), "PUT", "/update", "lead-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized(strings.Replace(random, "Random Inc.", "Other Inc.", 1), "PUT", "/update", "lead-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized("", "DELETE", "/delete?title=Other+App", "lead-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized("", "DELETE", "/delete?title=Random+App", "lead-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	// Read-only tokens stay read-only, whatever they are bound to.
+	rr = authorized("role: company-admin\ncompany: Other Inc.\nusers: [ viewer@random.com ]", "PUT", "/rolebindings", "admin-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+	rr = authorized("", "POST", "/search", "viewer-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized("", "DELETE", "/delete?title=Other+App", "viewer-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+
+	cleanup()
+}
+
+func TestBindingSources(t *testing.T) {
+	defer cleanup()
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+
+	// Subjects and groups must say which source they belong to, and can't be empty.
+	for _, invalid := range []string{"users: [ ci ]", "users: [ '' ]", "users: [ 'token:' ]", "groups: [ admins ]", "groups: [ 'ldap:admins' ]"} {
+		rr := authorized("role: global-admin\n"+invalid, "PUT", "/rolebindings", "admin-secret", t)
+		equals(t, http.StatusBadRequest, rr.Code)
+	}
+	rr := authorized("role: global-admin\nusers: [ 'token:ci' ]\ngroups: [ 'jwt:admins' ]", "PUT", "/rolebindings", "admin-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+
+	// A subject or group of the same name from another source doesn't claim the binding.
+	for _, c := range []struct {
+		id      *auth.Identity
+		granted bool
+	}{
+		{&auth.Identity{Source: auth.SourceToken, Subject: "ci"}, true},
+		{&auth.Identity{Source: auth.SourceJWT, Subject: "ci"}, false},
+		{&auth.Identity{Source: auth.SourceCertificate, Subject: "ci"}, false},
+		{&auth.Identity{Source: auth.SourceJWT, Subject: "someone", Groups: []string{"admins"}}, true},
+		{&auth.Identity{Source: auth.SourceCertificate, Subject: "someone", Groups: []string{"admins"}}, false},
+		{&auth.Identity{Source: auth.SourceToken}, false},
+	} {
+		equals(t, c.granted, server.RBAC.Decide(c.id, rbac.Admin, nil, nil) == "")
+	}
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	ok(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ok(t, err)
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": "%s", "e": "%s"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "%s", "y": "%s"}]}`,
+		b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()), b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	ok(t, ioutil.WriteFile(path, []byte(jwks), 0600))
+
+	verifier, err := auth.NewJWTVerifier(path)
+	ok(t, err)
+	verifier.Issuer = "https://idp.example.com"
+	verifier.GroupScopes = map[string][]string{"publishers": {auth.Write}, "catalog-admins": {auth.Admin}}
+	server.Auth.JWT = verifier
+
+	claims := func(email string, groups ...string) map[string]interface{} {
+		return map[string]interface{}{"iss": "https://idp.example.com", "sub": email, "email": email, "email_verified": true, "groups": groups, "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	owner := signJWT(t, "rsa", rsaKey, claims("firstmaintainer@hotmail.com", "publishers"))
+	stranger := signJWT(t, "ec", ecKey, claims("stranger@gmail.com", "publishers"))
+	admin := signJWT(t, "ec", ecKey, claims("admin@random.com", "catalog-admins"))
+
+	// Callers may only read unless a group grants them more.
+	reader := signJWT(t, "rsa", rsaKey, claims("firstmaintainer@hotmail.com"))
+	rr := authorized(yaml, "PUT", "/create", reader, t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized("title: Valid App 1", "POST", "/search", reader, t)
+	equals(t, http.StatusOK, rr.Code)
+
+	rr = authorized(yaml, "PUT", "/create", owner, t)
+	equals(t, http.StatusCreated, rr.Code)
+	rr = authorized(yaml, "PUT", "/update", stranger, t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized(yaml, "PUT", "/update", owner, t)
+	equals(t, http.StatusOK, rr.Code)
+	rr = authorized("", "GET", "/tokens", stranger, t)
+	equals(t, http.StatusForbidden, rr.Code)
+	rr = authorized("", "GET", "/tokens", admin, t)
+	equals(t, http.StatusOK, rr.Code)
+
+	expired := claims("firstmaintainer@hotmail.com", "publishers")
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	rr = authorized(yaml, "PUT", "/update", signJWT(t, "rsa", rsaKey, expired), t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	equals(t, "Authentication failed: JWT has expired\n", rr.Body.String())
+
+	untrusted := claims("firstmaintainer@hotmail.com", "publishers")
+	untrusted["iss"] = "https://evil.example.com"
+	rr = authorized(yaml, "PUT", "/update", signJWT(t, "rsa", rsaKey, untrusted), t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+
+	// Unverified emails don't confer ownership, unless the identity provider is trusted to verify every email.
+	unverified := claims("firstmaintainer@hotmail.com", "publishers")
+	unverified["email_verified"] = false
+	rr = authorized(yaml, "PUT", "/update", signJWT(t, "rsa", rsaKey, unverified), t)
+	equals(t, http.StatusForbidden, rr.Code)
+	delete(unverified, "email_verified")
+	rr = authorized(yaml, "PUT", "/update", signJWT(t, "rsa", rsaKey, unverified), t)
+	equals(t, http.StatusForbidden, rr.Code)
+	verifier.TrustUnverifiedEmail = true
+	rr = authorized(yaml, "PUT", "/update", signJWT(t, "rsa", rsaKey, unverified), t)
+	equals(t, http.StatusOK, rr.Code)
+	verifier.TrustUnverifiedEmail = false
+
+	// Swapping in another payload invalidates the signature.
+	parts := strings.Split(stranger, ".")
+	forged := strings.Split(owner, ".")
+	rr = authorized(yaml, "PUT", "/update", parts[0]+"."+forged[1]+"."+parts[2], t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	equals(t, "Authentication failed: JWT signature is invalid\n", rr.Body.String())
+
+	cleanup()
+}
+
+func TestMutualTLS(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	dir := t.TempDir()
+	caCert, caKey := issueCert(t, dir, "ca", &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}, IsCA: true, KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true}, nil, nil)
+	issueCert(t, dir, "server", &x509.Certificate{SerialNumber: big.NewInt(2), IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, caCert, caKey)
+	issueCert(t, dir, "owner", &x509.Certificate{Subject: pkix.Name{CommonName: "owner", OrganizationalUnit: []string{"platform"}}, EmailAddresses: []string{"firstmaintainer@hotmail.com"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, caCert, caKey)
+	issueCert(t, dir, "stranger", &x509.Certificate{Subject: pkix.Name{CommonName: "stranger"}, EmailAddresses: []string{"stranger@gmail.com"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, caCert, caKey)
+
+	reloader, err := certs.NewReloader(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	ok(t, err)
+	reloader.CheckInterval = 0
+	config, err := certs.ServerConfig(reloader, filepath.Join(dir, "ca.crt"), true)
+	ok(t, err)
+	server.Auth.ClientCertScopes = []string{auth.Write}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+	httpServer := &http.Server{Handler: server.Handler(), TLSConfig: config, ErrorLog: stdlog.New(ioutil.Discard, "", 0)}
+	go httpServer.ServeTLS(listener, "", "")
+	defer httpServer.Close()
+	url := "https://" + listener.Addr().String()
+
+	// request uses a fresh connection each time, so every call performs a handshake.
+	request := func(method string, path string, client string) (*http.Response, error) {
+		roots := x509.NewCertPool()
+		roots.AddCert(caCert)
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if client != "" {
+			pair, err := tls.LoadX509KeyPair(filepath.Join(dir, client+".crt"), filepath.Join(dir, client+".key"))
+			ok(t, err)
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+		req, err := http.NewRequest(method, url+path, strings.NewReader(yaml))
+		ok(t, err)
+		resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}).Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// Connections without a client certificate are refused during the handshake.
+	_, err = request("PUT", "/create", "")
+	assert(t, err != nil, "expected handshake without client certificate to fail")
+
+	resp, err := request("PUT", "/create", "owner")
+	ok(t, err)
+	equals(t, http.StatusCreated, resp.StatusCode)
+	resp, err = request("PUT", "/update", "stranger")
+	ok(t, err)
+	equals(t, http.StatusForbidden, resp.StatusCode)
+	resp, err = request("PUT", "/update", "owner")
+	ok(t, err)
+	equals(t, http.StatusOK, resp.StatusCode)
+	equals(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+
+	// Rotating the serving certificate on disk takes effect on the next handshake.
+	issueCert(t, dir, "server", &x509.Certificate{SerialNumber: big.NewInt(3), IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, caCert, caKey)
+	later := time.Now().Add(time.Minute)
+	ok(t, os.Chtimes(filepath.Join(dir, "server.crt"), later, later))
+	resp, err = request("PUT", "/update", "owner")
+	ok(t, err)
+	equals(t, big.NewInt(3), resp.TLS.PeerCertificates[0].SerialNumber)
+
+	cleanup()
+}
+
+// issueCert writes name.crt and name.key to dir, signed by parent or self-signed when parent is nil.
+func issueCert(t *testing.T, dir string, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ok(t, err)
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(1)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	ok(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	ok(t, err)
+	ok(t, ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	ok(t, ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	cert, err := x509.ParseCertificate(der)
+	ok(t, err)
+	return cert, key
+}
+
+// signJWT produces a compact JWS using RS256 for RSA keys and ES256 for EC keys.
+func signJWT(t *testing.T, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, isEC := key.(*ecdsa.PrivateKey); isEC {
+		alg = "ES256"
+	}
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	ok(t, err)
+	payload, err := json.Marshal(claims)
+	ok(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		ok(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		ok(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuditLog(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	since := time.Now().UTC().Add(-time.Second).Format(time.RFC3339)
+
+	rr := authorized(yaml, "PUT", "/create", "owner-secret", t)
+	equals(t, http.StatusCreated, rr.Code)
+	added := strings.Replace(yaml, "maintainers:\n", "maintainers:\n- name: second\n  email: second@gmail.com\n", 1)
+	rr = authorized(added, "PUT", "/update", "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	// Only admins may read the audit log.
+	rr = authorized("", "GET", "/audit", "owner-secret", t)
+	equals(t, http.StatusForbidden, rr.Code)
+
+	rr = authorized("", "GET", "/audit?title=Valid+App+1&actor=token:admin&since="+since, "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+	entries := []audit.Entry{}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &entries))
+	equals(t, 1, len(entries))
+	equals(t, "update", entries[0].Action)
+	equals(t, []audit.Change{{
+		Field:  "Maintainers",
+		Before: "firstmaintainer app1 <firstmaintainer@hotmail.com>",
+		After:  "second <second@gmail.com>, firstmaintainer app1 <firstmaintainer@hotmail.com>",
+	}}, entries[0].Changes)
+	assert(t, !entries[0].Time.IsZero(), "audit entry is missing its timestamp")
+
+	rr = authorized("", "GET", "/audit?email=FirstMaintainer@hotmail.com", "admin-secret", t)
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &entries))
+	equals(t, 1, len(entries))
+	equals(t, "create", entries[0].Action)
+	equals(t, "token:owner", entries[0].Actor)
+	equals(t, "", entries[0].PrevHash)
+
+	// Actors are qualified by how they authenticated, so a certificate named owner is someone else.
+	rr = authorized("", "GET", "/audit?actor=owner", "admin-secret", t)
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &entries))
+	equals(t, 0, len(entries))
+	rr = authorized("", "GET", "/audit?actor=cert:owner", "admin-secret", t)
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &entries))
+	equals(t, 0, len(entries))
+
+	rr = authorized("", "GET", "/audit?since=yesterday", "admin-secret", t)
+	equals(t, http.StatusBadRequest, rr.Code)
+
+	rr = authorized("", "GET", "/audit/verify", "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	cleanup()
+}
+
+func TestAuditLogTamperEvident(t *testing.T) {
+	log := &audit.Log{}
+	log.Record(audit.Entry{Actor: "a@random.com", Action: "create", Title: "App"})
+	second, err := log.Record(audit.Entry{Actor: "b@random.com", Action: "update", Title: "App"})
+	ok(t, err)
+	log.Record(audit.Entry{Actor: "c@random.com", Action: "delete", Title: "App"})
+	ok(t, log.Verify())
+
+	// Rewriting any entry breaks the chain from that entry on.
+	second.Actor = "someone-else@random.com"
+	equals(t, "audit log entry 2 has been tampered with", log.Verify().Error())
+}
+
+func TestAuditLogPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := audit.Open(path)
+	ok(t, err)
+	log.Record(audit.Entry{Actor: "a@random.com", Action: "create", Title: "App"})
+	second, err := log.Record(audit.Entry{Actor: "b@random.com", Action: "update", Title: "App"})
+	ok(t, err)
+	ok(t, log.Close())
+
+	// A restarted server picks up the chain where it left off.
+	log, err = audit.Open(path)
+	ok(t, err)
+	equals(t, 2, len(log.Find(audit.Query{})))
+	third, err := log.Record(audit.Entry{Actor: "c@random.com", Action: "delete", Title: "App"})
+	ok(t, err)
+	equals(t, 3, third.Seq)
+	equals(t, second.Hash, third.PrevHash)
+	ok(t, log.Close())
+
+	data, err := ioutil.ReadFile(path)
+	ok(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	equals(t, 3, len(lines))
+	entry := audit.Entry{}
+	ok(t, json.Unmarshal([]byte(lines[2]), &entry))
+	equals(t, *third, entry)
+
+	// Edits to the file are caught before anything is appended to it.
+	ok(t, ioutil.WriteFile(path, []byte(strings.Replace(string(data), "b@random.com", "someone-else@random.com", 1)), 0600))
+	_, err = audit.Open(path)
+	equals(t, path+": audit log entry 2 has been tampered with", err.Error())
+
+	// Entries streamed to stdout are the same structured records.
+	var out strings.Builder
+	audit.NewWriter(&out).Record(audit.Entry{Actor: "a@random.com", Action: "create", Title: "App"})
+	ok(t, json.Unmarshal([]byte(out.String()), &entry))
+	equals(t, "create", entry.Action)
+}
+
+func TestAuditLogWriteFailure(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	f, err := os.Create(filepath.Join(t.TempDir(), "audit.jsonl"))
+	ok(t, err)
+	server.AuditLog = audit.NewWriter(f)
+	ok(t, f.Close())
+
+	// A change the log can't record isn't made, and nothing is recorded after it, so the file's chain stays whole.
+	rr := authorized(yaml, "PUT", "/create", "owner-secret", t)
+	equals(t, http.StatusServiceUnavailable, rr.Code)
+	assert(t, strings.HasPrefix(rr.Body.String(), "failed to write the audit log"), "expected the audit failure to be reported, got %q", rr.Body.String())
+	equals(t, 0, len(server.Applications))
+	equals(t, 0, len(server.AuditLog.Find(audit.Query{})))
+
+	rr = authorized("name: ci\nscopes: [write]", "PUT", "/tokens", "admin-secret", t)
+	equals(t, http.StatusServiceUnavailable, rr.Code)
+	equals(t, 2, len(server.Auth.Tokens.List()))
+
+	// Reads carry on, while readiness sends traffic to replicas which can still record changes.
+	equals(t, http.StatusOK, authorized("title: Valid App 1", "POST", "/search", "owner-secret", t).Code)
+	equals(t, http.StatusServiceUnavailable, authorized("", "GET", "/readyz", "", t).Code)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	ok(t, server.TrustProxies("10.0.0.0/8", "192.0.2.1"))
+
+	send := func(method string, endpoint string, body string, remote string, forwarded string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+		ok(t, err)
+		req.Header.Set("Authorization", "Bearer owner-secret")
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+	ip := func() string {
+		entries := server.AuditLog.Find(audit.Query{})
+		return entries[len(entries)-1].IP
+	}
+
+	// Behind trusted proxies the client is the nearest hop they didn't add, whatever the client put before it.
+	equals(t, http.StatusCreated, send("PUT", "/create", yaml, "192.0.2.1:443", "198.51.100.1, 203.0.113.9, 10.1.2.3").Code)
+	equals(t, "203.0.113.9", ip())
+
+	// Anyone else's header is ignored, or clients could blame each other and dodge their limits.
+	updated := strings.Replace(yaml, "0.0.1", "0.0.2", 1)
+	equals(t, http.StatusOK, send("PUT", "/update", updated, "203.0.113.9:5000", "198.51.100.1").Code)
+	equals(t, "203.0.113.9", ip())
+
+	// A garbled hop is attributed to the proxy which passed it on.
+	equals(t, http.StatusOK, send("DELETE", "/delete?title=Valid+App+1", "", "192.0.2.1:443", "bogus, 10.1.2.3").Code)
+	equals(t, "10.1.2.3", ip())
+
+	equals(t, `invalid trusted proxy "proxy.internal"`, server.TrustProxies("proxy.internal").Error())
+}
+
+func TestRateLimit(t *testing.T) {
+	defer cleanup()
+	now := time.Now()
+	server.Limiter = ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2}, ratelimit.Limit{Rate: 0.5, Burst: 1}, ratelimit.Limit{Rate: 0.1, Burst: 2})
+	server.Limiter.Now = func() time.Time { return now }
+	alice, _ := server.Auth.Tokens.Issue("alice", "alice@random.com", []string{auth.Write}, time.Hour)
+	bob, _ := server.Auth.Tokens.Issue("bob", "bob@random.com", []string{auth.Write}, time.Hour)
+
+	rr := authorized("title: x", "POST", "/search", alice, t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+	equals(t, "1", rr.Header().Get("X-RateLimit-Remaining"))
+	equals(t, "1", rr.Header().Get("X-RateLimit-Reset"))
+	equals(t, http.StatusOK, authorized("title: x", "POST", "/search", alice, t).Code)
+	rr = authorized("title: x", "POST", "/search", alice, t)
+	equals(t, http.StatusTooManyRequests, rr.Code)
+	equals(t, "1", rr.Header().Get("Retry-After"))
+	equals(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+
+	// Budgets are per client and per class.
+	equals(t, http.StatusOK, authorized("title: x", "POST", "/search", bob, t).Code)
+	equals(t, http.StatusNotFound, authorized("", "DELETE", "/delete?title=x", alice, t).Code)
+	rr = authorized("", "DELETE", "/delete?title=x", alice, t)
+	equals(t, http.StatusTooManyRequests, rr.Code)
+	equals(t, "2", rr.Header().Get("Retry-After"))
+
+	// Buckets refill over time.
+	now = now.Add(time.Second)
+	equals(t, http.StatusOK, authorized("title: x", "POST", "/search", alice, t).Code)
+
+	// Guessing tokens spends the IP's budget of failures, after which its requests are refused before authenticating.
+	equals(t, http.StatusUnauthorized, authorized("title: x", "POST", "/search", "guess-1", t).Code)
+	equals(t, http.StatusUnauthorized, authorized("title: x", "POST", "/search", "", t).Code)
+	rr = authorized("title: x", "POST", "/search", "guess-2", t)
+	equals(t, http.StatusTooManyRequests, rr.Code)
+	equals(t, "10", rr.Header().Get("Retry-After"))
+	equals(t, http.StatusTooManyRequests, authorized("title: x", "POST", "/search", bob, t).Code)
+	now = now.Add(10 * time.Second)
+	equals(t, http.StatusOK, authorized("title: x", "POST", "/search", bob, t).Code)
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	defer cleanup()
+	server.MaxBodyBytes = 1024
+	defer func() { server.MaxBodyBytes = config.DefaultMaxBodyBytes }()
+	token, _ := server.Auth.Tokens.Issue("publisher", "publisher@random.com", []string{auth.Write}, time.Hour)
+
+	yaml := "title: Big App\ndescription: " + strings.Repeat("a", 2048)
+	rr := authorized(yaml, "PUT", "/create", token, t)
+	equals(t, http.StatusRequestEntityTooLarge, rr.Code)
+	equals(t, "Request body is too large, the limit is 1 KiB.\n", rr.Body.String())
+
+	rr = authorized(yaml, "POST", "/search", token, t)
+	equals(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestYAMLAliasesRejected(t *testing.T) {
+	defer cleanup()
+	// A scaled down billion laughs, each level multiplies the expansion.
+	yaml := `a: &a ["lol","lol","lol","lol","lol","lol","lol","lol","lol"]
+b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a]
+c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b]
+title: *c`
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to parse YAML input: YAML anchors and aliases are not supported.\n", rr.Body.String())
+
+	rr = execute("title: &t Anchored", "POST", "/search", server.Search, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestFieldLimits(t *testing.T) {
+	defer cleanup()
+	maintainers := ""
+	for i := 0; i < 51; i++ {
+		maintainers += fmt.Sprintf("- name: maintainer %d\n  email: maintainer%d@random.com\n", i, i)
+	}
+	yaml := `title: Crowded App
+version: 0.0.1
+maintainers:
+` + maintainers + `company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	assert(t, strings.Contains(rr.Body.String(), "ApplicationMetadata.Maintainers has invalid value"), "expected maintainers to be rejected, got %s", rr.Body.String())
+
+	yaml = strings.Replace(yaml, maintainers, "- name: "+strings.Repeat("n", 257)+"\n  email: long@random.com\n", 1)
+	rr = execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	assert(t, strings.Contains(rr.Body.String(), "ApplicationMetadata.Maintainers[0].Name has invalid value"), "expected name to be rejected, got %s", rr.Body.String())
+}
+
+func TestUnknownFields(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Misspelled App
+version: 0.0.1
+maintainers:
+- name: first last
+  emial: first@random.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+licence: Apache-2.0
+description: A really cool app.`
+	rr := execute(yaml, "PUT", "/create", server.Create, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	expected := `Failed to parse input, the following fields are unknown:
+maintainers[0].emial on line 5, did you mean email?
+licence on line 9, did you mean license?
+`
+	equals(t, expected, rr.Body.String())
+
+	// A dropped key would otherwise turn the search into match everything.
+	rr = execute("maintainer:\n- name: first last", "POST", "/search", server.Search, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to parse input, the following fields are unknown:\nmaintainer on line 1, did you mean maintainers?\n", rr.Body.String())
+
+	rr = execute("colour: blue", "POST", "/search", server.Search, t)
+	equals(t, "Failed to parse input, the following fields are unknown:\ncolour on line 1\n", rr.Body.String())
+}
+
+func TestJSONInput(t *testing.T) {
+	defer cleanup()
+	app := `{"title": "JSON App", "version": "0.0.1", "maintainers": [{"name": "first last", "email": "first@random.com"}],
+"company": "Random Inc.", "website": "https://website.com", "source": "https://github.com/random/repo",
+"license": "Apache-2.0", "description": "A really cool app."}`
+	req, err := http.NewRequest("PUT", "/create", strings.NewReader(app))
+	ok(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.Create).ServeHTTP(rr, req)
+	equals(t, http.StatusCreated, rr.Code)
+
+	req, err = http.NewRequest("POST", "/search", strings.NewReader(`{"licence": "Apache-2.0"}`))
+	ok(t, err)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(server.Search).ServeHTTP(rr, req)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to parse input, the following fields are unknown:\nlicence, did you mean license?\n", rr.Body.String())
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "upbound.yml")
+	ok(t, ioutil.WriteFile(file, []byte(`listen: ":9000"
+log:
+  level: debug
+limits:
+  readRate: 5
+  writeBurst: 3
+admission:
+  mutatingWebhooks: [https://file.example.com]
+`), 0600))
+	t.Setenv("UPBOUND_CONFIG", file)
+	t.Setenv("UPBOUND_LIMITS_READ_RATE", "7.5")
+	t.Setenv("UPBOUND_LOG_LEVEL", "warn")
+	t.Setenv("UPBOUND_ADMIN_TOKEN", "secret")
+
+	cfg, _, err := config.Load("upbound", []string{"-log-level", "error", "-mutating-webhook", "https://flag.example.com"})
+	ok(t, err)
+	equals(t, ":9000", cfg.Listen)      // file over default
+	equals(t, 3, cfg.Limits.WriteBurst) // file over default
+	equals(t, 7.5, cfg.Limits.ReadRate) // environment over file
+	equals(t, "error", cfg.Log.Level)   // flag over environment
+	equals(t, 40, cfg.Limits.ReadBurst) // default
+	equals(t, "secret", cfg.Auth.AdminToken)
+	equals(t, []string{"https://flag.example.com"}, cfg.Admission.MutatingWebhooks)
+	equals(t, "REDACTED", cfg.Redacted().Auth.AdminToken)
+
+	_, _, err = config.Load("upbound", []string{"-storage", "postgres"})
+	assert(t, err != nil && strings.Contains(err.Error(), "Config.Storage.Backend has invalid value postgres"), "expected invalid storage backend, got %v", err)
+	_, _, err = config.Load("upbound", []string{"-tls-cert", "cert.pem"})
+	equals(t, "invalid configuration: tls.cert and tls.key must be set together", err.Error())
+	_, _, err = config.Load("upbound", []string{"-jwks", "https://idp.example.com/jwks.json"})
+	equals(t, "invalid configuration: auth.jwks needs auth.jwtAudience, so tokens issued to other clients are refused", err.Error())
+	equals(t, []string{auth.Read}, cfg.Auth.JWTScopes)
+	// Every level /debug/loglevel can switch to can also be configured.
+	cfg, _, err = config.Load("upbound", []string{"-log-level", "trace"})
+	ok(t, err)
+	equals(t, "trace", cfg.Log.Level)
+
+	t.Setenv("UPBOUND_LIMITS_READ_BURST", "many")
+	_, _, err = config.Load("upbound", nil)
+	equals(t, `invalid value "many" of UPBOUND_LIMITS_READ_BURST: strconv.ParseInt: parsing "many": invalid syntax`, err.Error())
+
+	ok(t, ioutil.WriteFile(file, []byte("listne: \":9000\"\n"), 0600))
+	_, _, err = config.Load("upbound", nil)
+	assert(t, err != nil && strings.Contains(err.Error(), "field listne not found"), "expected unknown key to be rejected, got %v", err)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	defer cleanup()
+	admin, _ := server.Auth.Tokens.Issue("admin", "", []string{auth.Admin}, time.Hour)
+
+	// Probes carry no credentials.
+	rr := authorized("", "GET", "/healthz", "", t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, "ok\n", rr.Body.String())
+	rr = authorized("", "GET", "/readyz", "", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	rr = authorized("", "GET", "/debug/health", "", t)
+	equals(t, http.StatusUnauthorized, rr.Code)
+	rr = authorized("", "GET", "/debug/health", admin, t)
+	equals(t, http.StatusOK, rr.Code)
+	report := health.Report{}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &report))
+	equals(t, true, report.Ready)
+	equals(t, 3, len(report.Checks))
+	equals(t, "audit", report.Checks[0].Name)
+	equals(t, "storage", report.Checks[1].Name)
+	equals(t, "webhooks", report.Checks[2].Name)
+
+	// A failing subsystem fails readiness but not liveness.
+	server.Health.Register("jwks", func(ctx context.Context) error { return errors.New("keys are 2h0m0s old") })
+	rr = authorized("", "GET", "/readyz", "", t)
+	equals(t, http.StatusServiceUnavailable, rr.Code)
+	equals(t, "jwks: keys are 2h0m0s old\n", rr.Body.String())
+	equals(t, http.StatusOK, authorized("", "GET", "/healthz", "", t).Code)
+
+	// Hung checks time out rather than hanging the probe.
+	server.Health = health.NewRegistry()
+	server.Health.Register("stuck", func(ctx context.Context) error { select {} })
+	rr = authorized("", "GET", "/readyz", "", t)
+	equals(t, http.StatusServiceUnavailable, rr.Code)
+	equals(t, "stuck: check timed out: context deadline exceeded\n", rr.Body.String())
+
+	server.Health = health.NewRegistry()
+	server.Health.Drain()
+	rr = authorized("", "GET", "/readyz", "", t)
+	equals(t, http.StatusServiceUnavailable, rr.Code)
+	equals(t, "draining\n", rr.Body.String())
+}
+
+func TestMetrics(t *testing.T) {
+	defer cleanup()
+	token, _ := server.Auth.Tokens.Issue("publisher", "first@random.com", []string{auth.Write}, time.Hour)
+	yaml := `title: Metered App
+version: 0.0.1
+maintainers:
+- name: first last
+  email: first@random.com
+- name: second last
+  email: second@random.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	equals(t, http.StatusCreated, authorized(yaml, "PUT", "/create", token, t).Code)
+	invalid := strings.Replace(yaml, "email: second@random.com", "email: not-an-email", 1)
+	equals(t, http.StatusBadRequest, authorized(invalid, "PUT", "/create", token, t).Code)
+	equals(t, http.StatusOK, authorized("title: Metered App", "POST", "/search", token, t).Code)
+	equals(t, http.StatusOK, authorized("title: Metered App\nversion: 0.0.1", "POST", "/search", token, t).Code)
+	equals(t, http.StatusUnauthorized, authorized("", "POST", "/search", "", t).Code)
+	authorized("", "BREW", "/search", "", t)
+	authorized("", "PROPFIND", "/search", "", t)
+
+	rr := authorized("", "GET", "/metrics", token, t)
+	equals(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	for _, line := range []string{
+		`upbound_http_requests_total{code="201",method="PUT",route="/create"} 1`,
+		`upbound_http_requests_total{code="400",method="PUT",route="/create"} 1`,
+		`upbound_http_requests_total{code="200",method="POST",route="/search"} 2`,
+		`upbound_http_requests_total{code="401",method="POST",route="/search"} 1`,
+		`upbound_http_requests_total{code="401",method="other",route="/search"} 2`,
+		`upbound_http_request_duration_seconds_count{method="PUT",route="/create"} 2`,
+		`upbound_validation_rejections_total{field="ApplicationMetadata.Maintainers.Email",rule="email"} 1`,
+		`upbound_search_duration_seconds_count{query="title"} 1`,
+		`upbound_search_duration_seconds_count{query="compound"} 1`,
+		`upbound_catalog_applications 1`,
+		`upbound_catalog_maintainers 2`,
+		`upbound_catalog_companies 1`,
+	} {
+		assert(t, strings.Contains(body, line+"\n"), "expected metrics to contain %s", line)
+	}
+	assert(t, !strings.Contains(body, `method="BREW"`), "expected unknown methods to share a series")
+}
+
+// TestTracing follows a traced request through the handler, storage and into a webhook delivery.
+func TestTracing(t *testing.T) {
+	defer cleanup()
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := tracing.Setup(tracing.Options{Exporter: tracing.File, File: file, SampleRatio: 1, ServiceName: "upbound-test"})
+	ok(t, err)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	traceparents := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+	}))
+	defer receiver.Close()
+	rr := execute(fmt.Sprintf("url: %s\nsecret: s3cr3t", receiver.URL), "PUT", "/webhooks", server.WebhookSubscriptions, t)
+	equals(t, http.StatusCreated, rr.Code)
+	hookTraceparents := make(chan string, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hookTraceparents <- r.Header.Get("traceparent")
+		w.Write([]byte("allowed: true"))
+	}))
+	defer hook.Close()
+	server.Admission.AddValidator("traced", admission.NewWebhook(hook.URL))
+
+	token, _ := server.Auth.Tokens.Issue("publisher", "first@random.com", []string{auth.Write}, time.Hour)
+	yaml := `title: Traced App
+version: 0.0.1
+maintainers:
+- name: first last
+  email: first@random.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest("PUT", "/create", strings.NewReader(yaml))
+	ok(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+	equals(t, http.StatusCreated, rr.Code)
+
+	req, err = http.NewRequest("POST", "/search", strings.NewReader("title: Traced App"))
+	ok(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b8-01")
+	rr = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+	equals(t, http.StatusOK, rr.Code)
+
+	server.Webhooks.Wait()
+	traceparent := <-traceparents
+	assert(t, strings.HasPrefix(traceparent, "00-"+traceID+"-"), "expected the webhook to continue trace %s, got %q", traceID, traceparent)
+	traceparent = <-hookTraceparents
+	assert(t, strings.HasPrefix(traceparent, "00-"+traceID+"-"), "expected the admission hook to continue trace %s, got %q", traceID, traceparent)
+
+	ok(t, shutdown(context.Background()))
+	data, err := ioutil.ReadFile(file)
+	ok(t, err)
+	names := make(map[string]bool)
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	for decoder.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+		}
+		ok(t, decoder.Decode(&span))
+		// Subscribing the receiver above was traced too, but separately.
+		if span.SpanContext.TraceID == traceID {
+			names[span.Name] = true
+		}
+	}
+	for _, name := range []string{"PUT /create", "read body", "decode", "validate", "admission.validate", "store.insert", "webhook.deliver", "POST /search", "search.filter", "encode"} {
+		assert(t, names[name], "expected a %q span, got %v", name, names)
+	}
+}
+
+// TestAccessLog checks request IDs are honored or generated, and reach both the access log and handler log lines.
+func TestAccessLog(t *testing.T) {
+	defer cleanup()
+	var access, logs strings.Builder
+	logging.Access.SetOutput(&access)
+	logrus.SetOutput(&logs)
+	defer logging.Access.SetOutput(ioutil.Discard)
+	defer logrus.SetOutput(ioutil.Discard)
+
+	token, _ := server.Auth.Tokens.Issue("publisher", "first@random.com", []string{auth.Write}, time.Hour)
+	yaml := `title: Logged App
+version: 0.0.1
+maintainers:
+- name: first last
+  email: first@random.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	send := func(body string, method string, endpoint string, requestID string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
+		ok(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if requestID != "" {
+			req.Header.Set(handlers.RequestIDHeader, requestID)
+		}
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(yaml, "PUT", "/create", "create-1")
+	equals(t, http.StatusCreated, rr.Code)
+	equals(t, "create-1", rr.Header().Get(handlers.RequestIDHeader))
+	assert(t, strings.Contains(logs.String(), "request_id=create-1"), "expected handler logs to carry the request ID, got %s", logs.String())
+	equals(t, "create-1", server.AuditLog.Find(audit.Query{})[0].RequestID)
+
+	rr = send("title: Logged App", "POST", "/search", "")
+	equals(t, http.StatusOK, rr.Code)
+	generated := rr.Header().Get(handlers.RequestIDHeader)
+	equals(t, 32, len(generated))
+
+	// IDs which could corrupt log lines are replaced.
+	rr = send("title: Logged App", "POST", "/search", "bad id\n")
+	assert(t, rr.Header().Get(handlers.RequestIDHeader) != "bad id\n", "expected an unprintable request ID to be replaced")
+
+	lines := strings.Split(strings.TrimSpace(access.String()), "\n")
+	equals(t, 3, len(lines))
+	var create, search map[string]interface{}
+	ok(t, json.Unmarshal([]byte(lines[0]), &create))
+	ok(t, json.Unmarshal([]byte(lines[1]), &search))
+	equals(t, "create-1", create["request_id"])
+	equals(t, "PUT", create["method"])
+	equals(t, "/create", create["route"])
+	equals(t, float64(http.StatusCreated), create["status"])
+	equals(t, "publisher", create["identity"])
+	equals(t, generated, search["request_id"])
+	equals(t, float64(1), search["results"])
+	assert(t, search["bytes"].(float64) > 0, "expected the search response size to be logged, got %v", search["bytes"])
+	_, timed := search["latency_ms"].(float64)
+	assert(t, timed, "expected the latency to be logged, got %v", search["latency_ms"])
+}
+
+func TestLogLevel(t *testing.T) {
+	defer cleanup()
+	defer logrus.SetLevel(logrus.GetLevel())
+	admin, _ := server.Auth.Tokens.Issue("admin", "", []string{auth.Admin}, time.Hour)
+	writer, _ := server.Auth.Tokens.Issue("publisher", "first@random.com", []string{auth.Write}, time.Hour)
+
+	equals(t, http.StatusForbidden, authorized("level: debug", "PUT", "/debug/loglevel", writer, t).Code)
+	equals(t, http.StatusBadRequest, authorized("level: verbose", "PUT", "/debug/loglevel", admin, t).Code)
+
+	rr := authorized("level: debug", "PUT", "/debug/loglevel", admin, t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, logrus.DebugLevel, logrus.GetLevel())
+	rr = authorized("", "GET", "/debug/loglevel", admin, t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, "level: debug\n", rr.Body.String())
+	equals(t, "loglevel.set", server.AuditLog.Find(audit.Query{})[0].Action)
+}
+
+func TestSearchDebug(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	equals(t, http.StatusCreated, execute(yaml, "PUT", "/create", server.Create, t).Code)
+	equals(t, http.StatusCreated, execute(strings.Replace(yaml, "Valid App 1", "Valid App 2", 1), "PUT", "/create", server.Create, t).Code)
+
+	// The trace is attached whatever the global log level.
+	req, err := http.NewRequest("POST", "/search", strings.NewReader("title: Valid App 2"))
+	ok(t, err)
+	req.Header.Set(handlers.DebugHeader, "true")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(server.Search).ServeHTTP(rr, req)
+	equals(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Matches []*types.ApplicationMetadata `yaml:"matches"`
+		Trace   []struct {
+			Title   string `yaml:"title"`
+			Matched bool   `yaml:"matched"`
+			Steps   []struct {
+				Message string                 `yaml:"message"`
+				Fields  map[string]interface{} `yaml:"fields"`
+			} `yaml:"steps"`
+		} `yaml:"trace"`
+	}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &response))
+	equals(t, 1, len(response.Matches))
+	equals(t, "Valid App 2", response.Matches[0].Title)
+	equals(t, 2, len(response.Trace))
+	equals(t, "Valid App 1", response.Trace[0].Title)
+	equals(t, false, response.Trace[0].Matched)
+	equals(t, true, response.Trace[1].Matched)
+	// Comparison stops at the first mismatching field, the title.
+	last := response.Trace[0].Steps[len(response.Trace[0].Steps)-1]
+	equals(t, "Result of attempt", last.Message)
+	equals(t, "Title", last.Fields["field"])
+
+	// Without the header, the response is the usual list of matches.
+	rr = execute("title: Valid App 2", "POST", "/search", server.Search, t)
+	var matches []*types.ApplicationMetadata
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &matches))
+	equals(t, 1, len(matches))
+}
+
+func TestPprof(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/debug/pprof/", nil)
+	ok(t, err)
+	handlers.Pprof().ServeHTTP(rr, req)
+	equals(t, http.StatusOK, rr.Code)
+	assert(t, strings.Contains(rr.Body.String(), "goroutine"), "expected the pprof index, got %s", rr.Body.String())
+}
+
+func TestSearchExplain(t *testing.T) {
+	defer cleanup()
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+- name: secondmaintainer app1
+  email: secondmaintainer@gmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.`
+	equals(t, http.StatusCreated, execute(yaml, "PUT", "/create", server.Create, t).Code)
+
+	query := `title: Valid App 1
+version: 0.0.2
+maintainers:
+- name: secondmaintainer app1`
+	rr := execute(query, "POST", "/search?explain=true", server.Search, t)
+	equals(t, http.StatusOK, rr.Code)
+	var response struct {
+		Matches      []*types.ApplicationMetadata `yaml:"matches"`
+		Explanations []*util.Explanation          `yaml:"explanations"`
+	}
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &response))
+	equals(t, 0, len(response.Matches))
+	equals(t, 1, len(response.Explanations))
+	explanation := response.Explanations[0]
+	equals(t, "Valid App 1", explanation.Title)
+	equals(t, false, explanation.Matched)
+
+	// Every field is reported, not just up to the first mismatch.
+	equals(t, 8, len(explanation.Fields))
+	equals(t, util.FieldResult{Field: "title", Result: util.Matched}, explanation.Fields[0])
+	equals(t, util.FieldResult{Field: "version", Result: util.Mismatched, Query: "0.0.2", Known: "0.0.1"}, explanation.Fields[1])
+	equals(t, util.FieldResult{Field: "company", Result: util.Skipped}, explanation.Fields[3])
+
+	// The maintainer search tried the first maintainer, skipping the email missing from the query, before matching the second.
+	maintainers := explanation.Fields[2]
+	equals(t, util.Matched, maintainers.Result)
+	equals(t, 1, len(maintainers.Maintainers))
+	search := maintainers.Maintainers[0]
+	equals(t, true, search.Matched)
+	equals(t, 2, len(search.Tried))
+	equals(t, "firstmaintainer app1", search.Tried[0].Known.Name)
+	equals(t, false, search.Tried[0].Matched)
+	equals(t, []util.FieldResult{
+		{Field: "name", Result: util.Mismatched, Query: "secondmaintainer app1", Known: "firstmaintainer app1"},
+		{Field: "email", Result: util.Skipped},
+	}, search.Tried[0].Fields)
+	equals(t, true, search.Tried[1].Matched)
+
+	equals(t, http.StatusBadRequest, execute(query, "POST", "/search?explain=maybe", server.Search, t).Code)
+}
+
+// TestOpenAPI checks the served API description against what the handlers actually do, so it can't drift from them.
+func TestOpenAPI(t *testing.T) {
+	defer cleanup()
+	rr := authorized("", "GET", "/openapi.yaml", "", t)
+	equals(t, http.StatusOK, rr.Code)
+	var doc openapi.Document
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &doc))
+	equals(t, openapi.Version, doc.OpenAPI)
+
+	// Every route is described, and nothing else but the unauthenticated endpoints.
+	routes := server.Routes()
+	equals(t, len(routes)+4, len(doc.Paths))
+	for _, path := range []string{"/healthz", "/readyz", "/openapi.yaml", handlers.ApplicationSchemaPath} {
+		assert(t, doc.Paths[path] != nil, "expected %s to be described", path)
+	}
+
+	// Exactly the described methods are accepted. Requests are cancelled up front, so streams end right away.
+	admin := &auth.Identity{Subject: "admin", Scopes: []string{auth.Admin}}
+	for _, route := range routes {
+		item, described := doc.Paths[route.Path]
+		assert(t, described, "expected %s to be described", route.Path)
+		cancelled := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			cancel()
+			route.Handler(w, r.WithContext(ctx))
+		}
+		for _, method := range []string{"GET", "PUT", "POST", "DELETE", "PATCH"} {
+			rr := execute("", method, route.Path, as(admin, cancelled), t)
+			rejected := rr.Code == http.StatusBadRequest && strings.HasPrefix(rr.Body.String(), "Please use a")
+			_, documented := item[strings.ToLower(method)]
+			assert(t, documented != rejected, "%s %s is documented: %v, but rejected: %v", method, route.Path, documented, rejected)
+		}
+	}
+
+	// The schema's constraints are the ones validation enforces.
+	base := map[string]interface{}{
+		"title":       "Valid App",
+		"version":     "0.0.1",
+		"maintainers": []map[string]string{{"name": "first last", "email": "first@random.com"}},
+		"company":     "Random Inc.",
+		"website":     "https://website.com",
+		"source":      "https://github.com/random/repo",
+		"license":     "Apache-2.0",
+		"description": "A really cool app.",
+	}
+	n := 0
+	create := func(mutate func(app map[string]interface{})) int {
+		n++
+		app := make(map[string]interface{})
+		for k, v := range base {
+			app[k] = v
+		}
+		app["title"] = fmt.Sprintf("Valid App %d", n)
+		mutate(app)
+		data, err := yamlv2.Marshal(app)
+		ok(t, err)
+		return execute(string(data), "PUT", "/create", server.Create, t).Code
+	}
+	schema := doc.Components.Schemas["ApplicationMetadata"]
+	equals(t, len(base), len(schema.Properties))
+	equals(t, false, schema.AdditionalProperties)
+	equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) { app["unknown"] = "field" }))
+	for name, property := range schema.Properties {
+		_, known := base[name]
+		assert(t, known, "expected %s to be a field of applications", name)
+		required := false
+		for _, r := range schema.Required {
+			required = required || r == name
+		}
+		if required {
+			equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) { delete(app, name) }))
+		}
+		if property.MaxLength != nil {
+			max := *property.MaxLength
+			equals(t, http.StatusCreated, create(func(app map[string]interface{}) { app[name] = strings.Repeat(fmt.Sprint(n%10), max) }))
+			equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) { app[name] = strings.Repeat(fmt.Sprint(n%10), max+1) }))
+		}
+		if property.MaxItems != nil {
+			maintainers := func(count int) []map[string]string {
+				list := make([]map[string]string, count)
+				for i := range list {
+					list[i] = map[string]string{"name": "first last", "email": fmt.Sprintf("first%d@random.com", i)}
+				}
+				return list
+			}
+			equals(t, http.StatusCreated, create(func(app map[string]interface{}) { app[name] = maintainers(*property.MaxItems) }))
+			equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) { app[name] = maintainers(*property.MaxItems + 1) }))
+		}
+	}
+	maintainer := doc.Components.Schemas["Maintainer"]
+	equals(t, "email", maintainer.Properties["email"].Format)
+	equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) {
+		app["maintainers"] = []map[string]string{{"name": "first last", "email": "not-an-email"}}
+	}))
+	name := *maintainer.Properties["name"].MaxLength
+	equals(t, http.StatusBadRequest, create(func(app map[string]interface{}) {
+		app["maintainers"] = []map[string]string{{"name": strings.Repeat("a", name+1), "email": "first@random.com"}}
+	}))
+
+	// Searches aren't validated, so their schema requires nothing.
+	query := doc.Components.Schemas["ApplicationMetadataQuery"]
+	equals(t, 0, len(query.Required))
+	equals(t, http.StatusOK, execute("maintainers:\n- name: first last", "POST", "/search", server.Search, t).Code)
+}
+
+func TestDryRun(t *testing.T) {
+	yaml := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+description: A really cool app.`
+	defer cleanup()
+
+	// Nothing is stored, but the response shows the application after mutating hooks.
+	server.Admission.AddMutator("default-license", admission.DefaultLicense("Apache-2.0"))
+	rr := execute(yaml, "POST", "/validate", server.DryRun, t)
+	equals(t, http.StatusOK, rr.Code)
+	var admitted types.ApplicationMetadata
+	ok(t, yamlv2.Unmarshal(rr.Body.Bytes(), &admitted))
+	equals(t, "Apache-2.0", admitted.License)
+	equals(t, 0, len(server.Applications))
+	equals(t, http.StatusNotFound, execute(yaml, "POST", "/validate?operation=update", server.DryRun, t).Code)
+
+	// Titles must be unique when creating, and exist when updating.
+	equals(t, http.StatusCreated, execute(yaml, "PUT", "/create", server.Create, t).Code)
+	rr = execute(yaml, "POST", "/validate", server.DryRun, t)
+	equals(t, http.StatusConflict, rr.Code)
+	equals(t, "An application with title Valid App 1 already exists, please use a unique title.", rr.Body.String())
+	equals(t, http.StatusOK, execute(yaml, "POST", "/validate?operation=update", server.DryRun, t).Code)
+	equals(t, http.StatusBadRequest, execute(yaml, "POST", "/validate?operation=delete", server.DryRun, t).Code)
+
+	// Struct tags and policy are checked just like when storing.
+	rr = execute(strings.Replace(yaml, "firstmaintainer@hotmail.com", "not-an-email", 1), "POST", "/validate", server.DryRun, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to validate input of the following parameters:\nApplicationMetadata.Maintainers[0].Email has invalid value not-an-email\n", rr.Body.String())
+	p, err := policy.New([]policy.Rule{{Name: "approved-license", Expression: "License == 'MIT'", Message: "License must be MIT"}})
+	ok(t, err)
+	server.Policy = p
+	rr = execute(strings.Replace(yaml, "Valid App 1", "Valid App 2", 1), "POST", "/validate", server.DryRun, t)
+	equals(t, http.StatusBadRequest, rr.Code)
+	equals(t, "Failed to satisfy the following policies:\napproved-license: License must be MIT\n", rr.Body.String())
+	equals(t, 1, len(server.Applications))
+}
+
+func TestApplicationSchema(t *testing.T) {
+	// The schema is public, editors fetch it without credentials.
+	rr := authorized("", "GET", "http://upbound.example.com"+handlers.ApplicationSchemaPath, "", t)
+	equals(t, http.StatusOK, rr.Code)
+	equals(t, handlers.SchemaJSON, rr.Header().Get("Content-Type"))
+	var schema openapi.JSONSchema
+	ok(t, json.Unmarshal(rr.Body.Bytes(), &schema))
+	equals(t, openapi.JSONSchemaDraft, schema.Dialect)
+	equals(t, "http://upbound.example.com"+handlers.ApplicationSchemaPath, schema.ID)
+	equals(t, "#/definitions/ApplicationMetadata", schema.Ref)
+
+	// It describes manifests exactly as the API description does.
+	doc := server.Spec()
+	for _, name := range []string{"ApplicationMetadata", "Maintainer"} {
+		equals(t, doc.Components.Schemas[name].Required, schema.Definitions[name].Required)
+		equals(t, len(doc.Components.Schemas[name].Properties), len(schema.Definitions[name].Properties))
+	}
+	equals(t, "#/definitions/Maintainer", schema.Definitions["ApplicationMetadata"].Properties["maintainers"].Items.Ref)
+	equals(t, "email", schema.Definitions["Maintainer"].Properties["email"].Format)
+	equals(t, false, schema.Definitions["ApplicationMetadata"].AdditionalProperties)
+
+	// Validators only know the keywords' JSON names, so check them on the document rather than the Go struct.
+	var raw struct {
+		Definitions map[string]map[string]interface{} `json:"definitions"`
+	}
+	ok(t, json.Unmarshal(rr.Body.Bytes(), &raw))
+	for _, name := range []string{"ApplicationMetadata", "Maintainer"} {
+		equals(t, false, raw.Definitions[name]["additionalProperties"])
+		_, capitalized := raw.Definitions[name]["AdditionalProperties"]
+		assert(t, !capitalized, "expected no Go field names in the schema of %s", name)
+	}
+}
+
+func TestClient(t *testing.T) {
+	defer cleanup()
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	ctx := context.Background()
+	c := client.New(ts.URL, "admin-secret")
+
+	watcher, err := c.Watch(ctx, "")
+	ok(t, err)
+	defer watcher.Close()
+
+	app := &types.ApplicationMetadata{
+		Title:       "Valid App 1",
+		Version:     "0.0.1",
+		Maintainers: []*types.Maintainer{{Name: "firstmaintainer app1", Email: "firstmaintainer@hotmail.com"}},
+		Company:     "Random Inc.",
+		Website:     "https://website.com",
+		Source:      "https://github.com/random/repo",
+		License:     "Apache-2.0",
+		Description: "A really cool app.",
+	}
+	ok(t, c.Create(ctx, app))
+	got, err := c.Get(ctx, app.Title)
+	ok(t, err)
+	equals(t, app, got)
+	apps, err := c.Search(ctx, &types.ApplicationMetadata{Company: "Random Inc."})
+	ok(t, err)
+	equals(t, []*types.ApplicationMetadata{app}, apps)
+
+	// Refusals decode into errors matching their cause, with the server's explanation.
+	err = c.Create(ctx, app)
+	assert(t, errors.Is(err, client.ErrConflict), "expected a conflict, got %v", err)
+	var apiErr *client.Error
+	assert(t, errors.As(err, &apiErr), "expected an *Error, got %T", err)
+	equals(t, "An application with title Valid App 1 already exists, please use a unique title.", apiErr.Message)
+	assert(t, apiErr.RequestID != "", "expected the request ID")
+	invalid := *app
+	invalid.Title = "Valid App 2"
+	invalid.Maintainers = []*types.Maintainer{{Name: "first last", Email: "not-an-email"}}
+	err = c.Create(ctx, &invalid)
+	assert(t, errors.As(err, &apiErr) && errors.Is(err, client.ErrInvalid), "expected invalid input, got %v", err)
+	equals(t, []string{"ApplicationMetadata.Maintainers[0].Email has invalid value not-an-email"}, apiErr.Details)
+	_, err = client.New(ts.URL, "").Get(ctx, app.Title)
+	assert(t, errors.Is(err, client.ErrUnauthenticated), "expected unauthenticated, got %v", err)
+
+	updated := *app
+	updated.Version = "0.0.2"
+	ok(t, c.Update(ctx, &updated))
+	got, err = c.Get(ctx, app.Title)
+	ok(t, err)
+	equals(t, "0.0.2", got.Version)
+	ok(t, c.Delete(ctx, app.Title))
+	_, err = c.Get(ctx, app.Title)
+	assert(t, errors.Is(err, client.ErrNotFound), "expected not found, got %v", err)
+
+	// The watch saw every change, and ends with the server's watches.
+	for _, expected := range []struct {
+		event   string
+		version string
+	}{{webhooks.Created, "0.0.1"}, {webhooks.Updated, "0.0.2"}, {webhooks.Deleted, "0.0.2"}} {
+		event, err := watcher.Next()
+		ok(t, err)
+		equals(t, expected.event, event.Type)
+		equals(t, app.Title, event.Application.Title)
+		equals(t, expected.version, event.Application.Version)
+	}
+	server.Webhooks.EndWatches()
+	_, err = watcher.Next()
+	equals(t, io.EOF, err)
+}
+
+func TestClientRetries(t *testing.T) {
+	defer cleanup()
+	server.Auth.AnonymousRead = true
+	handler := server.Handler()
+	attempts, failures := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case attempts > failures:
+			handler.ServeHTTP(w, r)
+		case attempts%2 == 0:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "Too many requests.", http.StatusTooManyRequests)
+		default:
+			http.Error(w, "Unavailable.", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	c := client.New(ts.URL, "")
+	c.Backoff = time.Millisecond
+
+	// Server errors and rate limiting are retried until they pass.
+	failures = 3
+	apps, err := c.Search(context.Background(), &types.ApplicationMetadata{})
+	ok(t, err)
+	equals(t, 0, len(apps))
+	equals(t, 4, attempts)
+
+	// Or until attempts run out.
+	attempts, failures = 0, 10
+	_, err = c.Search(context.Background(), &types.ApplicationMetadata{})
+	assert(t, errors.Is(err, client.ErrRateLimited), "expected rate limiting, got %v", err)
+	equals(t, c.MaxAttempts, attempts)
+
+	// Client errors are not retried.
+	attempts, failures = 0, 0
+	_, err = c.Get(context.Background(), "Missing App")
+	assert(t, errors.Is(err, client.ErrNotFound), "expected not found, got %v", err)
+	equals(t, 1, attempts)
+
+	// A create stored by an attempt whose response was lost succeeds, rather than conflicting with itself on the retry.
+	server.Auth.AnonymousRead = false
+	server.Auth.Tokens.Add("owner-secret", "owner", "firstmaintainer@hotmail.com", []string{auth.Write}, time.Hour)
+	c.Token = "owner-secret"
+	app := &types.ApplicationMetadata{
+		Title:       "Valid App 1",
+		Version:     "0.0.1",
+		Maintainers: []*types.Maintainer{{Name: "firstmaintainer app1", Email: "firstmaintainer@hotmail.com"}},
+		Company:     "Random Inc.",
+		Website:     "https://website.com",
+		Source:      "https://github.com/random/repo",
+		License:     "Apache-2.0",
+		Description: "A really cool app.",
+	}
+	lost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "Bad gateway.", http.StatusBadGateway)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer lost.Close()
+	c.BaseURL = lost.URL
+	attempts = 0
+	ok(t, c.Create(context.Background(), app))
+	equals(t, 1, len(server.Applications))
+
+	// A stored application differing from the one sent still conflicts.
+	attempts = 0
+	changed := *app
+	changed.Version = "0.0.2"
+	err = c.Create(context.Background(), &changed)
+	assert(t, errors.Is(err, client.ErrConflict), "expected a conflict, got %v", err)
+}
+
+func TestCtl(t *testing.T) {
+	defer cleanup()
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+	ctl := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr strings.Builder
+		code := cli.Run(append(args[:1:1], append([]string{"-server", ts.URL, "-token", "admin-secret"}, args[1:]...)...), strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+	manifest := `title: Valid App 1
+version: 0.0.1
+maintainers:
+- name: firstmaintainer app1
+  email: firstmaintainer@hotmail.com
+company: Random Inc.
+website: https://website.com
+source: https://github.com/random/repo
+license: Apache-2.0
+description: A really cool app.
+---
+title: Valid App 2
+version: 1.0.0
+maintainers:
+- name: secondmaintainer app2
+  email: secondmaintainer@gmail.com
+company: Other Inc.
+website: https://website.com
+source: https://github.com/other/repo
+license: MIT
+description: Another cool app.
+---
+`
+	file := filepath.Join(t.TempDir(), "apps.yaml")
+	ok(t, ioutil.WriteFile(file, []byte(manifest), 0o600))
+
+	// Applying creates every document, and updates them the second time around.
+	code, stdout, stderr := ctl("", "apply", "-f", file)
+	equals(t, 0, code)
+	equals(t, "application \"Valid App 1\" created\napplication \"Valid App 2\" created\n", stdout)
+	code, stdout, _ = ctl(strings.Replace(manifest, "0.0.1", "0.0.2", 1), "apply", "-f", "-")
+	equals(t, 0, code)
+	equals(t, "application \"Valid App 1\" updated\napplication \"Valid App 2\" updated\n", stdout)
+
+	code, stdout, _ = ctl("", "get", "-o", "json", "Valid App 1")
+	equals(t, 0, code)
+	var app map[string]interface{}
+	ok(t, json.Unmarshal([]byte(stdout), &app))
+	equals(t, "0.0.2", app["version"])
+	code, stdout, _ = ctl("", "get", "-o", "yaml", "Valid App 1")
+	equals(t, 0, code)
+	equals(t, "title: Valid App 1\nversion: 0.0.2\n", stdout[:strings.Index(stdout, "maintainers")])
+
+	code, stdout, _ = ctl("", "search", "-maintainer-email", "secondmaintainer@gmail.com")
+	equals(t, 0, code)
+	equals(t, "TITLE        VERSION  COMPANY     LICENSE  MAINTAINERS\nValid App 2  1.0.0    Other Inc.  MIT      secondmaintainer@gmail.com\n", stdout)
+	code, stdout, _ = ctl("", "search", "-o", "go-template={{range .}}{{.title}}:{{.license}} {{end}}")
+	equals(t, 0, code)
+	equals(t, "Valid App 1:Apache-2.0 Valid App 2:MIT ", stdout)
+
+	// Refusals are reported with the server's explanation.
+	code, _, stderr = ctl("", "get", "Missing App")
+	equals(t, 1, code)
+	equals(t, "upbound responded 404: No application with title Missing App exists.\n", stderr)
+	code, _, _ = ctl("", "get", "-o", "xml", "Valid App 1")
+	equals(t, 2, code)
+
+	code, stdout, _ = ctl("", "delete", "Valid App 1", "Valid App 2")
+	equals(t, 0, code)
+	equals(t, "application \"Valid App 1\" deleted\napplication \"Valid App 2\" deleted\n", stdout)
+	equals(t, 0, len(server.Applications))
+
+	// Validation needs no server, and reports every invalid field like the server does.
+	invalid := strings.Replace(manifest, "secondmaintainer@gmail.com", "not-an-email", 1)
+	var out, errOut strings.Builder
+	code = cli.Run([]string{"validate", "-server", "http://127.0.0.1:1", "-f", "-"}, strings.NewReader(invalid), &out, &errOut)
+	equals(t, 1, code)
+	equals(t, "application \"Valid App 1\" is valid\napplication \"Valid App 2\" is invalid:\n  ApplicationMetadata.Maintainers[0].Email has invalid value not-an-email\n", out.String())
+	equals(t, "1 of 2 applications are invalid\n", errOut.String())
+	code = cli.Run([]string{"validate", "-f", "-"}, strings.NewReader(manifest+"unknown: field\n"), &out, &errOut)
+	equals(t, 1, code)
+}
+
+func TestGRPC(t *testing.T) {
+	defer cleanup()
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	server.Auth.Tokens.Add("reader-secret", "reader", "", []string{auth.Read}, time.Hour)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+	grpcServer := server.GRPC(nil)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	ok(t, err)
+	defer conn.Close()
+	registry := upboundv1.NewRegistryClient(conn)
+	ctx := context.Background()
+	admin := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer admin-secret")
+
+	watch, err := registry.Watch(admin, &upboundv1.WatchRequest{})
+	ok(t, err)
+	// Wait for the headers, which are sent once the watch is registered, so it sees every change below.
+	header, err := watch.Header()
+	ok(t, err)
+	equals(t, 1, len(header.Get(handlers.RequestIDHeader)))
+
+	app := &upboundv1.Application{
+		Title:       "Valid App 1",
+		Version:     "0.0.1",
+		Maintainers: []*upboundv1.Maintainer{{Name: "firstmaintainer app1", Email: "firstmaintainer@hotmail.com"}},
+		Company:     "Random Inc.",
+		Website:     "https://website.com",
+		Source:      "https://github.com/random/repo",
+		License:     "Apache-2.0",
+		Description: "A really cool app.",
+	}
+	created, err := registry.Create(admin, &upboundv1.CreateRequest{Application: app})
+	ok(t, err)
+	equals(t, app.Title, created.Title)
+	got, err := registry.Get(admin, &upboundv1.GetRequest{Title: app.Title})
+	ok(t, err)
+	equals(t, app.Maintainers[0].Email, got.Maintainers[0].Email)
+	list, err := registry.List(admin, &upboundv1.ListRequest{})
+	ok(t, err)
+	equals(t, 1, len(list.Applications))
+	found, err := registry.Search(admin, &upboundv1.SearchRequest{Query: &upboundv1.Application{Company: "Random Inc."}})
+	ok(t, err)
+	equals(t, 1, len(found.Applications))
+
+	// Both APIs share one store.
+	rr := authorized("", "GET", "/get?title=Valid+App+1", "admin-secret", t)
+	equals(t, http.StatusOK, rr.Code)
+
+	// Refusals map to the status codes matching their cause.
+	code := func(err error) codes.Code { return status.Code(err) }
+	_, err = registry.Create(admin, &upboundv1.CreateRequest{Application: app})
+	equals(t, codes.AlreadyExists, code(err))
+	invalid := proto.Clone(app).(*upboundv1.Application)
+	invalid.Title = "Valid App 2"
+	invalid.Maintainers[0].Email = "not-an-email"
+	_, err = registry.Create(admin, &upboundv1.CreateRequest{Application: invalid})
+	equals(t, codes.InvalidArgument, code(err))
+	details := status.Convert(err).Details()
+	equals(t, 1, len(details))
+	equals(t, "ApplicationMetadata.Maintainers[0].Email", details[0].(*errdetails.BadRequest).FieldViolations[0].Field)
+	_, err = registry.Get(ctx, &upboundv1.GetRequest{Title: app.Title})
+	equals(t, codes.Unauthenticated, code(err))
+	reader := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer reader-secret")
+	_, err = registry.Get(reader, &upboundv1.GetRequest{Title: app.Title})
+	ok(t, err)
+	_, err = registry.Delete(reader, &upboundv1.DeleteRequest{Title: app.Title})
+	equals(t, codes.PermissionDenied, code(err))
+
+	updated := proto.Clone(app).(*upboundv1.Application)
+	updated.Version = "0.0.2"
+	_, err = registry.Update(admin, &upboundv1.UpdateRequest{Application: updated})
+	ok(t, err)
+	got, err = registry.Get(admin, &upboundv1.GetRequest{Title: app.Title})
+	ok(t, err)
+	equals(t, "0.0.2", got.Version)
+	_, err = registry.Delete(admin, &upboundv1.DeleteRequest{Title: app.Title})
+	ok(t, err)
+	_, err = registry.Get(admin, &upboundv1.GetRequest{Title: app.Title})
+	equals(t, codes.NotFound, code(err))
+	_, err = registry.Delete(admin, &upboundv1.DeleteRequest{Title: app.Title})
+	equals(t, codes.NotFound, code(err))
+
+	// Failed authentications are limited per IP, like over HTTP.
+	server.Limiter = ratelimit.New(ratelimit.Limit{}, ratelimit.Limit{}, ratelimit.Limit{Rate: 0.1, Burst: 1})
+	guess := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer guess")
+	_, err = registry.List(guess, &upboundv1.ListRequest{})
+	equals(t, codes.Unauthenticated, code(err))
+	_, err = registry.List(admin, &upboundv1.ListRequest{})
+	equals(t, codes.ResourceExhausted, code(err))
+
+	// The watch saw every change, and ends with the server's watches.
+	for _, expected := range []struct {
+		event   upboundv1.Event_Type
+		version string
+	}{{upboundv1.Event_TYPE_CREATED, "0.0.1"}, {upboundv1.Event_TYPE_UPDATED, "0.0.2"}, {upboundv1.Event_TYPE_DELETED, "0.0.2"}} {
+		event, err := watch.Recv()
+		ok(t, err)
+		equals(t, expected.event, event.Type)
+		equals(t, app.Title, event.Application.Title)
+		equals(t, expected.version, event.Application.Version)
+	}
+	server.Webhooks.EndWatches()
+	_, err = watch.Recv()
+	equals(t, io.EOF, err)
+}
+
+// TestGRPCInstrumented checks gRPC calls are traced and counted like HTTP requests, and survive panicking methods.
+func TestGRPCInstrumented(t *testing.T) {
+	defer cleanup()
+	file := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := tracing.Setup(tracing.Options{Exporter: tracing.File, File: file, SampleRatio: 1, ServiceName: "upbound-test"})
+	ok(t, err)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	server.Auth.Tokens.Add("admin-secret", "admin", "", []string{auth.Admin}, time.Hour)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ok(t, err)
+	grpcServer := server.GRPC(nil)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	ok(t, err)
+	defer conn.Close()
+	registry := upboundv1.NewRegistryClient(conn)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	admin := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin-secret", "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	app := &upboundv1.Application{
+		Title:       "Valid App 1",
+		Version:     "0.0.1",
+		Maintainers: []*upboundv1.Maintainer{{Name: "firstmaintainer app1", Email: "firstmaintainer@hotmail.com"}},
+		Company:     "Random Inc.",
+		Website:     "https://website.com",
+		Source:      "https://github.com/random/repo",
+		License:     "Apache-2.0",
+		Description: "A really cool app.",
+	}
+	_, err = registry.Create(admin, &upboundv1.CreateRequest{Application: app})
+	ok(t, err)
+
+	// A panic fails the call rather than the server.
+	server.Admission.AddValidator("panicking", admission.ValidatorFunc(func(app *types.ApplicationMetadata) error {
+		panic("boom")
+	}))
+	app.Title = "Valid App 2"
+	_, err = registry.Create(admin, &upboundv1.CreateRequest{Application: app})
+	equals(t, codes.Internal, status.Code(err))
+	list, err := registry.List(admin, &upboundv1.ListRequest{})
+	ok(t, err)
+	equals(t, 1, len(list.Applications))
+
+	rr := httptest.NewRecorder()
+	server.Metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`upbound_grpc_requests_total{code="OK",method="/upbound.v1.Registry/Create"} 1`,
+		`upbound_grpc_requests_total{code="Internal",method="/upbound.v1.Registry/Create"} 1`,
+		`upbound_grpc_requests_total{code="OK",method="/upbound.v1.Registry/List"} 1`,
+		`upbound_grpc_request_duration_seconds_count{method="/upbound.v1.Registry/Create"} 2`,
+	} {
+		assert(t, strings.Contains(rr.Body.String(), line), "expected metrics to contain %s", line)
+	}
+
+	ok(t, shutdown(context.Background()))
+	data, err := ioutil.ReadFile(file)
+	ok(t, err)
+	names := make(map[string]bool)
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	for decoder.More() {
+		var span struct {
+			Name        string
+			SpanContext struct{ TraceID string }
+		}
+		ok(t, decoder.Decode(&span))
+		if span.SpanContext.TraceID == traceID {
+			names[span.Name] = true
+		}
+	}
+	for _, name := range []string{"upbound.v1.Registry/Create", "admission.validate", "store.insert", "upbound.v1.Registry/List"} {
+		assert(t, names[name], "expected a %q span, got %v", name, names)
+	}
+}
+
+// FuzzParse feeds arbitrary documents to every handler which parses a body, none may panic or fail with a server error.
+func FuzzParse(f *testing.F) {
+	f.Add("title: Valid App 1\nversion: 0.0.1\nmaintainers:\n- name: first last\n  email: first@random.com\ncompany: Random Inc.\nwebsite: https://website.com\nsource: https://github.com/random/repo\nlicense: Apache-2.0\ndescription: An app.")
+	f.Add("maintainers: {name: x}")
+	f.Add("a: &a [x]\nb: *a")
+	f.Add("title: [[[[[[[[[[")
+	f.Add("- - - -\n")
+	f.Add("!!binary title: x")
+	f.Fuzz(func(t *testing.T, yaml string) {
+		defer cleanup()
+		for _, h := range []struct {
+			method string
+			f      func(http.ResponseWriter, *http.Request)
+		}{
+			{"PUT", server.Create},
+			{"PUT", server.Update},
+			{"POST", server.Search},
+			{"POST", server.PolicyTest},
+			{"POST", server.DryRun},
+		} {
+			rr := execute(yaml, h.method, "/", as(&auth.Identity{Subject: "fuzz", Email: "first@random.com", Scopes: []string{auth.Admin}}, h.f), t)
+			assert(t, rr.Code < 500, "%d response to %q: %s", rr.Code, yaml, rr.Body.String())
+		}
+	})
+}
+
+// as runs a handler on behalf of the identity, as if the authentication middleware had accepted it.
+func as(id *auth.Identity, f func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f(w, r.WithContext(auth.NewContext(r.Context(), id)))
+	}
+}
+
+// authorized sends a request through the full server handler, including authentication, with an optional bearer token.
+func authorized(yaml string, method string, endpoint string, token string, t *testing.T) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(yaml))
+	ok(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rr := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rr, req)
+	return rr
+}
+
 // execute assists generating HTTP requests for testing purposes.
 func execute(yaml string, method string, endpoint string, f func(http.ResponseWriter, *http.Request), t *testing.T) *httptest.ResponseRecorder {
 	// Read data, create a request manually, instantiate recording apparatus.
@@ -400,6 +2669,17 @@ func execute(yaml string, method string, endpoint string, f func(http.ResponseWr
 // cleanup clears stored application metadata on the server in between test runs.
 func cleanup() {
 	server.Applications = []*types.ApplicationMetadata{}
+	server.Transfers = nil
+	server.Webhooks = webhooks.NewDispatcher()
+	server.Admission = &admission.Chain{}
+	server.Policy = nil
+	server.Auth = &auth.Authenticator{Tokens: auth.NewTokenStore()}
+	server.RBAC = &rbac.Authorizer{}
+	server.AuditLog = &audit.Log{}
+	server.Limiter = nil
+	server.Health = health.NewRegistry()
+	server.Metrics = metrics.New()
+	server.TrustedProxies = nil
 }
 
 // FUNCTIONS BELOW THIS LINE COURTESTY OF https://github.com/benbjohnson/testing
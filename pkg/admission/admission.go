@@ -0,0 +1,233 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Validator inspects an application before it is persisted, returning an error to reject it. The context is the
+// request's, so hooks calling out are cancelled with it and continue its trace.
+type Validator interface {
+	Validate(ctx context.Context, app *types.ApplicationMetadata) error
+}
+
+// Mutator modifies an application in place before it is validated and persisted.
+type Mutator interface {
+	Mutate(ctx context.Context, app *types.ApplicationMetadata) error
+}
+
+// ValidatorFunc adapts a plain function to the Validator interface.
+type ValidatorFunc func(app *types.ApplicationMetadata) error
+
+// Validate calls f(app).
+func (f ValidatorFunc) Validate(ctx context.Context, app *types.ApplicationMetadata) error {
+	return f(app)
+}
+
+// MutatorFunc adapts a plain function to the Mutator interface.
+type MutatorFunc func(app *types.ApplicationMetadata) error
+
+// Mutate calls f(app).
+func (f MutatorFunc) Mutate(ctx context.Context, app *types.ApplicationMetadata) error {
+	return f(app)
+}
+
+// Rejection is returned when a hook refuses to admit an application.
+type Rejection struct {
+	Hook   string
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("Rejected by admission hook %s: %s", r.Hook, r.Reason)
+}
+
+// Failure is returned when an external hook could not be consulted and fails closed.
+type Failure struct {
+	Hook string
+	Err  error
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("Admission hook %s failed: %v", f.Hook, f.Err)
+}
+
+// Chain runs all registered mutators in order, then all validators in order.
+type Chain struct {
+	mutators   []namedMutator
+	validators []namedValidator
+}
+
+type namedMutator struct {
+	name string
+	Mutator
+}
+
+type namedValidator struct {
+	name string
+	Validator
+}
+
+// AddMutator appends a mutating hook to the chain.
+func (c *Chain) AddMutator(name string, m Mutator) {
+	c.mutators = append(c.mutators, namedMutator{name, m})
+}
+
+// AddValidator appends a validating hook to the chain.
+func (c *Chain) AddValidator(name string, v Validator) {
+	c.validators = append(c.validators, namedValidator{name, v})
+}
+
+// Mutate applies every mutator to the application, stopping at the first error.
+func (c *Chain) Mutate(ctx context.Context, app *types.ApplicationMetadata) error {
+	for _, m := range c.mutators {
+		if err := m.Mutate(ctx, app); err != nil {
+			return wrap(m.name, err)
+		}
+		logging.FromContext(ctx).WithFields(log.Fields{"hook": m.name, "name": app.Title}).Debug("Mutated by admission hook")
+	}
+	return nil
+}
+
+// Validate runs every validator against the application, stopping at the first error.
+func (c *Chain) Validate(ctx context.Context, app *types.ApplicationMetadata) error {
+	for _, v := range c.validators {
+		if err := v.Validate(ctx, app); err != nil {
+			return wrap(v.name, err)
+		}
+	}
+	return nil
+}
+
+// wrap attributes errors to the hook's registered name, treating plain errors from in-process hooks as rejections.
+func wrap(name string, err error) error {
+	switch err := err.(type) {
+	case *Rejection:
+		err.Hook = name
+		return err
+	case *Failure:
+		err.Hook = name
+		return err
+	default:
+		return &Rejection{Hook: name, Reason: err.Error()}
+	}
+}
+
+// DefaultLicense is a mutator which fills in a license when none was provided.
+type DefaultLicense string
+
+// Mutate sets the license if it is empty.
+func (d DefaultLicense) Mutate(ctx context.Context, app *types.ApplicationMetadata) error {
+	if app.License == "" {
+		app.License = string(d)
+	}
+	return nil
+}
+
+// Review is the payload exchanged with external admission endpoints.
+// The server sends the application, the endpoint answers with allowed, an optional reason and an optional patch.
+type Review struct {
+	Application *types.ApplicationMetadata `yaml:"application,omitempty"`
+	Allowed     bool                       `yaml:"allowed"`
+	Reason      string                     `yaml:"reason,omitempty"`
+	Patch       *types.ApplicationMetadata `yaml:"patch,omitempty"` // Non-empty fields overwrite those of the application.
+}
+
+// DefaultMaxReviewBytes bounds the reviews webhooks respond with unless configured otherwise, far above any real one.
+const DefaultMaxReviewBytes = 1 << 20
+
+// Webhook calls an external HTTP endpoint to admit applications. It implements both Validator and Mutator.
+type Webhook struct {
+	URL            string
+	FailOpen       bool  // Admit applications when the endpoint is unreachable or misbehaves.
+	MaxReviewBytes int64 // Largest review read from the endpoint, larger ones count as misbehaving. Unlimited when zero.
+	Client         *http.Client
+}
+
+// NewWebhook returns an admission webhook for the given URL which fails closed.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, MaxReviewBytes: DefaultMaxReviewBytes, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Validate asks the endpoint whether the application is allowed, ignoring any patch.
+func (h *Webhook) Validate(ctx context.Context, app *types.ApplicationMetadata) error {
+	_, err := h.review(ctx, app)
+	return err
+}
+
+// Mutate asks the endpoint whether the application is allowed and applies its patch.
+func (h *Webhook) Mutate(ctx context.Context, app *types.ApplicationMetadata) error {
+	review, err := h.review(ctx, app)
+	if err != nil || review == nil || review.Patch == nil {
+		return err
+	}
+	util.Merge(app, review.Patch)
+	return nil
+}
+
+// review performs the round trip, returning a nil review without error when failing open.
+func (h *Webhook) review(ctx context.Context, app *types.ApplicationMetadata) (*Review, error) {
+	review, err := h.call(ctx, app)
+	if err != nil {
+		if h.FailOpen {
+			logging.FromContext(ctx).WithFields(log.Fields{"url": h.URL, "error": err}).Info("Admission webhook failed, admitting anyway")
+			return nil, nil
+		}
+		return nil, &Failure{Hook: h.URL, Err: err}
+	}
+	if !review.Allowed {
+		return nil, &Rejection{Hook: h.URL, Reason: review.Reason}
+	}
+	return review, nil
+}
+
+// call posts the application to the endpoint, within the request's context and trace.
+func (h *Webhook) call(ctx context.Context, app *types.ApplicationMetadata) (*Review, error) {
+	body, err := yaml.Marshal(&Review{Application: app})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	tracing.Inject(ctx, req.Header)
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	var reader io.Reader = resp.Body
+	if h.MaxReviewBytes > 0 {
+		// One byte over the limit is enough to tell a review is too large without reading the rest.
+		reader = io.LimitReader(resp.Body, h.MaxReviewBytes+1)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if h.MaxReviewBytes > 0 && int64(len(data)) > h.MaxReviewBytes {
+		return nil, fmt.Errorf("review is larger than %d bytes", h.MaxReviewBytes)
+	}
+	review := &Review{}
+	if err := yaml.Unmarshal(data, review); err != nil {
+		return nil, fmt.Errorf("malformed review: %v", err)
+	}
+	return review, nil
+}
@@ -0,0 +1,4 @@
+// Package upboundv1 is the generated gRPC API of the registry, see registry.proto.
+package upboundv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative upbound/v1/registry.proto
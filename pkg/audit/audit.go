@@ -0,0 +1,239 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/types"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Change is the before and after value of a single application field.
+type Change struct {
+	Field  string `yaml:"field" json:"field"`
+	Before string `yaml:"before" json:"before"`
+	After  string `yaml:"after" json:"after"`
+}
+
+// Entry records one mutation. Each entry's hash covers its contents and the previous entry's hash,
+// so altering or removing any entry breaks the chain from that point on.
+type Entry struct {
+	Seq       int       `yaml:"seq" json:"seq"`
+	Time      time.Time `yaml:"time" json:"time"`
+	Actor     string    `yaml:"actor" json:"actor"`                     // Principal of the caller qualified by how they authenticated, e.g. token:ci.
+	Email     string    `yaml:"email,omitempty" json:"email,omitempty"` // Email of the caller, where their identity has one.
+	IP        string    `yaml:"ip" json:"ip"`
+	RequestID string    `yaml:"requestId,omitempty" json:"requestId,omitempty"`
+	Action    string    `yaml:"action" json:"action"`
+	Title     string    `yaml:"title,omitempty" json:"title,omitempty"`   // Application the action applied to.
+	Target    string    `yaml:"target,omitempty" json:"target,omitempty"` // ID of the token, binding or subscription the action applied to.
+	Changes   []Change  `yaml:"changes,omitempty" json:"changes,omitempty"`
+	PrevHash  string    `yaml:"prevHash" json:"prevHash"`
+	Hash      string    `yaml:"hash" json:"hash"`
+}
+
+// digest hashes every field but Hash itself.
+func (e *Entry) digest() string {
+	unhashed := *e
+	unhashed.Hash = ""
+	data, err := yaml.Marshal(&unhashed)
+	if err != nil {
+		// Entries only hold strings, ints and times, which always marshal.
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Query filters entries, empty fields match everything.
+type Query struct {
+	Title string
+	Actor string
+	Email string // Matched case insensitively.
+	Since time.Time
+}
+
+// maxLine bounds the JSON line of one entry when reading a log file back, generously above the largest application diff.
+const maxLine = 1 << 20
+
+// Log is an append-only, hash-chained list of entries. The zero value keeps entries in memory only, NewWriter and Open
+// also write every entry out as a line of JSON.
+type Log struct {
+	mu      sync.RWMutex
+	entries []*Entry
+	out     io.Writer
+	err     error // Set once writing to out failed, after which nothing more is recorded.
+}
+
+// WriteError is returned by Record once the log could not be written. Later entries are refused too, rather than
+// recorded in memory only, so what was written still forms a chain Open accepts.
+type WriteError struct {
+	Err error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("failed to write the audit log, changes are refused until the server is restarted: %v", e.Err)
+}
+
+// NewWriter returns a log writing entries to w, such as standard output for a log collector to keep.
+func NewWriter(w io.Writer) *Log {
+	return &Log{out: w}
+}
+
+// Open returns a log appending entries to the file at path, creating it if needed. The entries already in the file are
+// loaded and verified, so the chain continues across restarts and tampering with the file stops the server starting.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{out: f}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for scanner.Scan() {
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %v", path, len(l.entries)+1, err)
+		}
+		l.entries = append(l.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := l.Verify(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Close closes the file the log writes to, if any.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.out.(io.Closer); ok && l.out != os.Stdout && l.out != os.Stderr {
+		return c.Close()
+	}
+	return nil
+}
+
+// Record appends an entry, filling in its sequence number, time and hashes.
+// The returned entry is the stored one and must not be modified. Entries are written out before they are kept, and a
+// failed write returns a *WriteError, so the mutation the entry describes must not be made.
+func (l *Log) Record(e Entry) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	e.Seq = len(l.entries) + 1
+	e.Time = time.Now().UTC()
+	if len(l.entries) > 0 {
+		e.PrevHash = l.entries[len(l.entries)-1].Hash
+	}
+	e.Hash = e.digest()
+	if l.out != nil {
+		// One write per entry, so concurrent writers to the same file or stream never interleave lines.
+		data, err := json.Marshal(&e)
+		if err == nil {
+			_, err = l.out.Write(append(data, '\n'))
+		}
+		if err != nil {
+			// Part of the line may have been written, which Open will report, but nothing after it is.
+			l.err = &WriteError{Err: err}
+			log.WithFields(log.Fields{"seq": e.Seq, "error": err}).Error("Failed to write audit log entry")
+			return nil, l.err
+		}
+	}
+	l.entries = append(l.entries, &e)
+	return &e, nil
+}
+
+// Err returns the *WriteError which stopped the log recording, if any.
+func (l *Log) Err() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.err
+}
+
+// Check is a health check failing once the log can no longer be written.
+func (l *Log) Check(ctx context.Context) error {
+	return l.Err()
+}
+
+// Find returns copies of the entries matching the query, oldest first.
+func (l *Log) Find(q Query) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	found := make([]Entry, 0)
+	for _, e := range l.entries {
+		if (q.Title == "" || e.Title == q.Title) && (q.Actor == "" || e.Actor == q.Actor) && (q.Email == "" || strings.EqualFold(e.Email, q.Email)) && !e.Time.Before(q.Since) {
+			found = append(found, *e)
+		}
+	}
+	return found
+}
+
+// Verify recomputes the chain, returning an error naming the first entry which was tampered with.
+func (l *Log) Verify() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	prev := ""
+	for i, e := range l.entries {
+		if e.Seq != i+1 || e.PrevHash != prev || e.Hash != e.digest() {
+			return fmt.Errorf("audit log entry %d has been tampered with", i+1)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Diff lists the fields which differ between two versions of an application. Either may be nil for creates and deletes.
+func Diff(before *types.ApplicationMetadata, after *types.ApplicationMetadata) []Change {
+	beforeVal := reflect.ValueOf(before)
+	afterVal := reflect.ValueOf(after)
+	t := reflect.TypeOf(types.ApplicationMetadata{})
+	changes := make([]Change, 0)
+	for i := 0; i < t.NumField(); i++ {
+		b, a := "", ""
+		if before != nil {
+			b = render(beforeVal.Elem().Field(i).Interface())
+		}
+		if after != nil {
+			a = render(afterVal.Elem().Field(i).Interface())
+		}
+		if a != b {
+			changes = append(changes, Change{Field: t.Field(i).Name, Before: b, After: a})
+		}
+	}
+	return changes
+}
+
+// render flattens a field to a string, listing maintainers as "name <email>".
+func render(v interface{}) string {
+	switch v := v.(type) {
+	case []*types.Maintainer:
+		maintainers := make([]string, 0, len(v))
+		for _, m := range v {
+			if m != nil {
+				maintainers = append(maintainers, fmt.Sprintf("%s <%s>", m.Name, m.Email))
+			}
+		}
+		return strings.Join(maintainers, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Scopes granted to identities. Each scope implies the ones before it, so admin can also write and read.
+const (
+	Read  = "read"
+	Write = "write"
+	Admin = "admin"
+)
+
+var ranks = map[string]int{Read: 1, Write: 2, Admin: 3}
+
+// tokenPrefix makes tokens recognizable to secret scanners and humans.
+const tokenPrefix = "upb_"
+
+// Errors returned when authenticating a bearer token.
+var (
+	ErrInvalidToken = errors.New("invalid bearer token")
+	ErrExpiredToken = errors.New("bearer token has expired")
+)
+
+// Sources callers authenticate through. Subjects and groups are only unique within their source.
+const (
+	SourceToken       = "token"
+	SourceCertificate = "cert"
+	SourceJWT         = "jwt"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	Source  string // One of the Source constants.
+	Subject string
+	Email   string // Matched against application maintainers to decide ownership.
+	Groups  []string
+	Scopes  []string
+}
+
+// Principal returns the subject qualified by its source, e.g. token:ci, so subjects of different sources never collide.
+func (id *Identity) Principal() string {
+	return id.Source + ":" + id.Subject
+}
+
+// Allows returns true if any of the identity's scopes implies the required scope.
+func (id *Identity) Allows(scope string) bool {
+	for _, s := range id.Scopes {
+		if ranks[s] >= ranks[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the identity.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the identity stored in ctx, or nil for anonymous requests.
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(contextKey{}).(*Identity)
+	return id
+}
+
+// Token is an issued API token. Only a hash of the secret is ever stored.
+type Token struct {
+	ID      string    `yaml:"id"`
+	Name    string    `yaml:"name"`
+	Email   string    `yaml:"email,omitempty"`
+	Scopes  []string  `yaml:"scopes"`
+	Created time.Time `yaml:"created"`
+	Expires time.Time `yaml:"expires"`
+	Hash    string    `yaml:"-"`
+}
+
+// TokenStore holds issued tokens keyed by the hash of their secret.
+type TokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]*Token
+}
+
+// NewTokenStore returns an empty token store.
+func NewTokenStore() *TokenStore {
+	return &TokenStore{tokens: make(map[string]*Token)}
+}
+
+// Issue creates a token with a fresh secret, returning the secret which cannot be recovered later.
+func (s *TokenStore) Issue(name string, email string, scopes []string, ttl time.Duration) (string, *Token) {
+	secret := tokenPrefix + util.NewID() + util.NewID()
+	return secret, s.Add(secret, name, email, scopes, ttl)
+}
+
+// Add stores a token for a caller-provided secret, used to bootstrap the first admin token.
+func (s *TokenStore) Add(secret string, name string, email string, scopes []string, ttl time.Duration) *Token {
+	now := time.Now().UTC()
+	token := &Token{ID: util.NewID(), Name: name, Email: email, Scopes: scopes, Created: now, Expires: now.Add(ttl), Hash: hash(secret)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token.Hash] = token
+	return token
+}
+
+// Revoke deletes the token with the given ID, returning false if it did not exist.
+func (s *TokenStore) Revoke(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, token := range s.tokens {
+		if token.ID == id {
+			delete(s.tokens, h)
+			return true
+		}
+	}
+	return false
+}
+
+// List returns all tokens, including expired ones, oldest first.
+func (s *TokenStore) List() []*Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]*Token, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Created.Before(tokens[j].Created) })
+	return tokens
+}
+
+// Authenticate resolves a presented secret to the identity of its token.
+func (s *TokenStore) Authenticate(secret string) (*Identity, error) {
+	s.mu.RLock()
+	token, ok := s.tokens[hash(secret)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(token.Expires) {
+		return nil, ErrExpiredToken
+	}
+	return &Identity{Source: SourceToken, Subject: token.Name, Email: token.Email, Scopes: token.Scopes}, nil
+}
+
+// Authenticator guards handlers with bearer token or client certificate authentication.
+// Bearer tokens shaped like JWTs are checked by the JWT verifier when one is configured, anything else against issued API tokens.
+// Without a bearer token, a verified client certificate identifies the caller when ClientCertScopes is set.
+type Authenticator struct {
+	Tokens           *TokenStore
+	JWT              *JWTVerifier
+	ClientCertScopes []string // Scopes granted to callers presenting a verified client certificate.
+	AnonymousRead    bool     // Let requests without credentials through to read-only routes.
+}
+
+// Authenticate wraps a handler so it runs with the caller's identity in the request context.
+// Requests without credentials get 401, unless anonymous is set and AnonymousRead allows them through without an identity.
+func (a *Authenticator) Authenticate(anonymous bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, ok := bearer(r)
+		if !ok {
+			if id := a.certificate(r.TLS); id != nil {
+				next.ServeHTTP(w, identified(r, id))
+				return
+			}
+			if anonymous && a.AnonymousRead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="upbound"`)
+			http.Error(w, "Please provide a bearer token in the Authorization header.", http.StatusUnauthorized)
+			return
+		}
+		id, err := a.authenticate(secret)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="upbound", error="invalid_token"`)
+			http.Error(w, "Authentication failed: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, identified(r, id))
+	})
+}
+
+// identified attaches the identity to the request, and its subject to the request's log lines.
+func identified(r *http.Request, id *Identity) *http.Request {
+	logging.AddFields(r.Context(), log.Fields{"identity": id.Subject})
+	return r.WithContext(NewContext(r.Context(), id))
+}
+
+// Identify authenticates a caller which didn't come through Authenticate, such as over gRPC: by the bearer token when
+// one was presented, else by the verified client certificate of the connection. It returns nil without an error when
+// neither identifies the caller.
+func (a *Authenticator) Identify(secret string, state *tls.ConnectionState) (*Identity, error) {
+	if secret != "" {
+		return a.authenticate(secret)
+	}
+	return a.certificate(state), nil
+}
+
+// certificate returns the identity of a verified client certificate, or nil if there is none or they aren't trusted.
+func (a *Authenticator) certificate(state *tls.ConnectionState) *Identity {
+	if len(a.ClientCertScopes) == 0 || state == nil || len(state.VerifiedChains) == 0 {
+		return nil
+	}
+	return CertificateIdentity(state.VerifiedChains[0][0], a.ClientCertScopes)
+}
+
+// CertificateIdentity maps a client certificate to an identity: the subject's common name (or full DN without one)
+// as the subject, the first email SAN as the email and the subject's organizational units as groups.
+func CertificateIdentity(cert *x509.Certificate, scopes []string) *Identity {
+	id := &Identity{Source: SourceCertificate, Subject: cert.Subject.CommonName, Groups: cert.Subject.OrganizationalUnit, Scopes: scopes}
+	if id.Subject == "" {
+		id.Subject = cert.Subject.String()
+	}
+	if len(cert.EmailAddresses) > 0 {
+		id.Email = cert.EmailAddresses[0]
+	}
+	return id
+}
+
+func (a *Authenticator) authenticate(secret string) (*Identity, error) {
+	if a.JWT != nil && strings.Count(secret, ".") == 2 {
+		return a.JWT.Verify(secret)
+	}
+	return a.Tokens.Authenticate(secret)
+}
+
+// ValidScope returns true for the scopes tokens may be issued with.
+func ValidScope(scope string) bool {
+	_, ok := ranks[scope]
+	return ok
+}
+
+func bearer(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), true
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Owns returns true if the identity's email belongs to one of the application's maintainers.
+func (id *Identity) Owns(app *types.ApplicationMetadata) bool {
+	if id == nil || id.Email == "" {
+		return false
+	}
+	for _, m := range app.Maintainers {
+		if m != nil && strings.EqualFold(m.Email, id.Email) {
+			return true
+		}
+	}
+	return false
+}
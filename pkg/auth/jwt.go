@@ -0,0 +1,351 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// jwksRefreshInterval limits how often an unknown key ID or health check triggers refetching a remote JWKS.
+const jwksRefreshInterval = time.Minute
+
+// jwksMaxAge is how long cached keys keep the verifier healthy while its source can't be refetched.
+const jwksMaxAge = time.Hour
+
+// Errors returned when verifying a JWT.
+var (
+	ErrMalformedJWT  = errors.New("malformed JWT")
+	ErrUnknownKey    = errors.New("JWT signed by unknown key")
+	ErrBadSignature  = errors.New("JWT signature is invalid")
+	ErrExpiredJWT    = errors.New("JWT has expired")
+	ErrJWTNotYet     = errors.New("JWT is not valid yet")
+	ErrWrongIssuer   = errors.New("JWT issuer is not trusted")
+	ErrWrongAudience = errors.New("JWT audience does not include this server")
+)
+
+// JWTVerifier authenticates RS256 and ES256 signed JWTs against a JSON Web Key Set.
+type JWTVerifier struct {
+	Source   string // File path or http(s) URL of the JWKS.
+	Issuer   string // Required iss claim, unchecked when empty.
+	Audience string // Required entry of the aud claim, unchecked when empty.
+
+	EmailClaim  string              // Claim holding the caller's email, defaults to email.
+	GroupsClaim string              // Claim holding the caller's groups, defaults to groups.
+	Scopes      []string            // Scopes granted to every verified caller, defaults to read.
+	GroupScopes map[string][]string // Extra scopes granted to members of a group.
+
+	// Use the email even when the email_verified claim isn't true, for identity providers which only issue verified
+	// emails but omit the claim. Emails decide ownership, so unverified ones are ignored by default.
+	TrustUnverifiedEmail bool
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time // When the keys were last loaded.
+	attempted time.Time // When loading them was last tried, successfully or not.
+}
+
+// NewJWTVerifier loads the key set from source, failing if it cannot be read or contains no usable keys.
+func NewJWTVerifier(source string) (*JWTVerifier, error) {
+	v := &JWTVerifier{Source: source, EmailClaim: "email", GroupsClaim: "groups", Scopes: []string{Read}}
+	if err := v.Refresh(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Refresh reloads the key set from its source.
+func (v *JWTVerifier) Refresh() error {
+	v.mu.Lock()
+	v.attempted = time.Now()
+	v.mu.Unlock()
+	var data []byte
+	var err error
+	if strings.HasPrefix(v.Source, "http://") || strings.HasPrefix(v.Source, "https://") {
+		data, err = fetch(v.Source)
+	} else {
+		data, err = ioutil.ReadFile(v.Source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS from %s: %v", v.Source, err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS from %s: %v", v.Source, err)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.fetched = time.Now()
+	return nil
+}
+
+// Check is a health check which refreshes the key set when it is due. A failed refresh only fails the check once the
+// cached keys are older than jwksMaxAge, so a brief identity provider outage doesn't take every replica out of service.
+func (v *JWTVerifier) Check(ctx context.Context) error {
+	v.mu.RLock()
+	age := time.Since(v.fetched)
+	v.mu.RUnlock()
+	if !v.due() {
+		return nil
+	}
+	if err := v.Refresh(); err != nil {
+		if age > jwksMaxAge {
+			return fmt.Errorf("keys are %s old: %v", age.Round(time.Second), err)
+		}
+		log.WithFields(log.Fields{"error": err}).Info("JWKS refresh failed")
+	}
+	return nil
+}
+
+// Verify checks the token's signature and registered claims and maps its claims to an identity.
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedJWT
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedJWT
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedJWT
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, digest[:], signature) {
+		return nil, ErrBadSignature
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedJWT
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return v.identity(claims), nil
+}
+
+// key looks up a key by ID, refetching remote key sets once in a while to pick up rotated keys.
+func (v *JWTVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if !v.due() {
+		return nil, ErrUnknownKey
+	}
+	if err := v.Refresh(); err != nil {
+		log.WithFields(log.Fields{"error": err}).Info("JWKS refresh failed")
+		return nil, ErrUnknownKey
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if key, ok = v.keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// due claims the next refresh of a key set last loaded, or last tried to be loaded, over jwksRefreshInterval ago. Failed
+// attempts count, so made-up key IDs can't make every request refetch the key set while its source is down or slow.
+func (v *JWTVerifier) due() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if time.Since(v.attempted) < jwksRefreshInterval {
+		return false
+	}
+	v.attempted = time.Now()
+	return true
+}
+
+func (v *JWTVerifier) checkClaims(claims map[string]interface{}) error {
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || now >= exp {
+		return ErrExpiredJWT
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return ErrJWTNotYet
+	}
+	if v.Issuer != "" && claims["iss"] != v.Issuer {
+		return ErrWrongIssuer
+	}
+	if v.Audience != "" && !contains(claimStrings(claims["aud"]), v.Audience) {
+		return ErrWrongAudience
+	}
+	return nil
+}
+
+func (v *JWTVerifier) identity(claims map[string]interface{}) *Identity {
+	id := &Identity{Source: SourceJWT, Scopes: append([]string{}, v.Scopes...)}
+	id.Subject, _ = claims["sub"].(string)
+	if v.TrustUnverifiedEmail || verified(claims["email_verified"]) {
+		id.Email, _ = claims[v.EmailClaim].(string)
+	}
+	id.Groups = claimStrings(claims[v.GroupsClaim])
+	for _, group := range id.Groups {
+		id.Scopes = append(id.Scopes, v.GroupScopes[group]...)
+	}
+	return id
+}
+
+// verified returns true for an email_verified claim of true, which some identity providers send as a string.
+func verified(claim interface{}) bool {
+	switch claim := claim.(type) {
+	case bool:
+		return claim
+	case string:
+		return claim == "true"
+	default:
+		return false
+	}
+}
+
+// ParseJWKS extracts the RSA and EC public keys from a JSON Web Key Set, keyed by key ID.
+// Keys of other types or curves are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	set := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := decodeInt(k.N)
+			e, err2 := decodeInt(k.E)
+			if err1 != nil || err2 != nil || !e.IsInt64() {
+				return nil, fmt.Errorf("invalid RSA key %s", k.Kid)
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if k.Crv != "P-256" {
+				continue
+			}
+			x, err1 := decodeInt(k.X)
+			y, err2 := decodeInt(k.Y)
+			if err1 != nil || err2 != nil || !elliptic.P256().IsOnCurve(x, y) {
+				return nil, fmt.Errorf("invalid EC key %s", k.Kid)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+// verifySignature checks a JWS signature, refusing algorithms which don't match the key type.
+func verifySignature(alg string, key crypto.PublicKey, digest []byte, signature []byte) bool {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature) == nil
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		// JWS encodes ECDSA signatures as the fixed width concatenation of r and s.
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest, r, s)
+	default:
+		return false
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// claimStrings normalizes claims which may be a single string or a list of strings.
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		values := make([]string, 0, len(c))
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,114 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reloader serves a certificate from disk, picking up rotated files without a restart.
+type Reloader struct {
+	CertFile      string
+	KeyFile       string
+	CheckInterval time.Duration // Minimum time between checks of the files' modification times.
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewReloader loads the key pair, failing if it cannot be read.
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{CertFile: certFile, KeyFile: keyFile, CheckInterval: 10 * time.Second}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, reloading the pair if either file changed.
+// A failed reload keeps serving the previous certificate, since a rotation may be half written.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) >= r.CheckInterval {
+		r.checked = time.Now()
+		if modTime, err := latestModTime(r.CertFile, r.KeyFile); err == nil && !modTime.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				log.WithFields(log.Fields{"cert": r.CertFile, "error": err}).Error("Failed to reload certificate")
+			} else {
+				log.WithFields(log.Fields{"cert": r.CertFile}).Info("Reloaded certificate")
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *Reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+// load reads the pair, callers must hold the lock.
+func (r *Reloader) load() error {
+	modTime, err := latestModTime(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// ServerConfig builds a TLS configuration serving the reloader's certificate.
+// With a client CA bundle, client certificates are verified against it, and required if requireClientCert is set.
+func ServerConfig(reloader *Reloader, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	config := &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, errors.New("requiring client certificates needs a client CA bundle to verify them against")
+		}
+		return config, nil
+	}
+	data, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA bundle %s", clientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
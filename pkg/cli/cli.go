@@ -0,0 +1,354 @@
+// Package cli implements upboundctl, the command-line client of the upbound API.
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/alexeldeib/upbound/pkg/client"
+	"github.com/alexeldeib/upbound/pkg/types"
+	validator "gopkg.in/go-playground/validator.v9"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Environment variables the connection flags default to. Prefer them over -token, which shows up in process listings.
+const (
+	ServerEnv = "UPBOUND_SERVER"
+	TokenEnv  = "UPBOUND_TOKEN"
+)
+
+// DefaultServer is the server used when neither -server nor UPBOUND_SERVER is set.
+const DefaultServer = "http://localhost:8080"
+
+// errUsage marks errors caused by how the command was called, which exit with status 2.
+var errUsage = errors.New("usage")
+
+// command is a subcommand of upboundctl.
+type command struct {
+	usage   string
+	summary string
+	run     func(c *ctl, args []string) error
+}
+
+var commands = map[string]command{
+	"apply":    {"apply -f FILE", "Create or update every application in a manifest, - reads standard input.", (*ctl).apply},
+	"get":      {"get [-o FORMAT] TITLE", "Show an application.", (*ctl).get},
+	"search":   {"search [-o FORMAT] [-FIELD VALUE]...", "List applications matching every field given.", (*ctl).search},
+	"delete":   {"delete TITLE...", "Delete applications.", (*ctl).delete},
+	"validate": {"validate -f FILE", "Check every application in a manifest without contacting the server.", (*ctl).validate},
+}
+
+// ctl is one invocation of upboundctl.
+type ctl struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	server string
+	token  string
+	output string
+}
+
+// Run executes upboundctl with the command-line arguments following the program name, returning its exit status.
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	c := &ctl{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %s.\n\n", args[0])
+		c.usage()
+		return 2
+	}
+	err := cmd.run(c, args[1:])
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "%v\nUsage: upboundctl %s\n", err, cmd.usage)
+		return 2
+	default:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+}
+
+func (c *ctl) usage() {
+	fmt.Fprintln(c.stderr, "upboundctl manages applications in an upbound registry.\n\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(c.stderr, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", commands[name].usage, commands[name].summary)
+	}
+	w.Flush()
+	fmt.Fprintf(c.stderr, "\nThe server and token are read from %s and %s, or -server and -token.\n", ServerEnv, TokenEnv)
+}
+
+// flags returns the flags of a command, including the connection flags every command accepts.
+func (c *ctl) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("upboundctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	server := os.Getenv(ServerEnv)
+	if server == "" {
+		server = DefaultServer
+	}
+	fs.StringVar(&c.server, "server", server, "URL of the upbound server.")
+	fs.StringVar(&c.token, "token", os.Getenv(TokenEnv), "Bearer token to authenticate with.")
+	return fs
+}
+
+// outputFlag adds the -o flag to commands which print applications.
+func (c *ctl) outputFlag(fs *flag.FlagSet) {
+	fs.StringVar(&c.output, "o", "table", "Output format: table, yaml, json or go-template=TEMPLATE.")
+}
+
+func (c *ctl) client() *client.Client {
+	return client.New(c.server, c.token)
+}
+
+// apply creates every application in the manifest, updating those which already exist.
+func (c *ctl) apply(args []string) error {
+	fs := c.flags("apply")
+	file := fs.String("f", "", "Manifest of one or more applications, - reads standard input.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || fs.NArg() > 0 {
+		return fmt.Errorf("%w: apply takes a manifest with -f and no arguments", errUsage)
+	}
+	apps, err := c.read(*file)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	api := c.client()
+	for _, app := range apps {
+		err := api.Create(ctx, app)
+		action := "created"
+		if errors.Is(err, client.ErrConflict) {
+			err = api.Update(ctx, app)
+			action = "updated"
+		}
+		if err != nil {
+			return fmt.Errorf("application %q: %w", app.Title, err)
+		}
+		fmt.Fprintf(c.stdout, "application %q %s\n", app.Title, action)
+	}
+	return nil
+}
+
+func (c *ctl) get(args []string) error {
+	fs := c.flags("get")
+	c.outputFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%w: get takes the title of one application", errUsage)
+	}
+	app, err := c.client().Get(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.print([]*types.ApplicationMetadata{app}, app)
+}
+
+func (c *ctl) search(args []string) error {
+	fs := c.flags("search")
+	c.outputFlag(fs)
+	query := &types.ApplicationMetadata{}
+	fs.StringVar(&query.Title, "title", "", "Title to match.")
+	fs.StringVar(&query.Version, "version", "", "Version to match.")
+	fs.StringVar(&query.Company, "company", "", "Company to match.")
+	fs.StringVar(&query.Website, "website", "", "Website to match.")
+	fs.StringVar(&query.Source, "source", "", "Source to match.")
+	fs.StringVar(&query.License, "license", "", "License to match.")
+	fs.StringVar(&query.Description, "description", "", "Description to match.")
+	maintainer := &types.Maintainer{}
+	fs.StringVar(&maintainer.Name, "maintainer-name", "", "Name of a maintainer to match.")
+	fs.StringVar(&maintainer.Email, "maintainer-email", "", "Email of a maintainer to match.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%w: search takes flags only", errUsage)
+	}
+	if maintainer.Name != "" || maintainer.Email != "" {
+		query.Maintainers = []*types.Maintainer{maintainer}
+	}
+	apps, err := c.client().Search(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	return c.print(apps, apps)
+}
+
+func (c *ctl) delete(args []string) error {
+	fs := c.flags("delete")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("%w: delete takes the titles of the applications", errUsage)
+	}
+	api := c.client()
+	for _, title := range fs.Args() {
+		if err := api.Delete(context.Background(), title); err != nil {
+			return fmt.Errorf("application %q: %w", title, err)
+		}
+		fmt.Fprintf(c.stdout, "application %q deleted\n", title)
+	}
+	return nil
+}
+
+// validate checks the manifest against the same struct tags as the server, without the server's admission hooks,
+// policy or uniqueness checks.
+func (c *ctl) validate(args []string) error {
+	fs := c.flags("validate")
+	file := fs.String("f", "", "Manifest of one or more applications, - reads standard input.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || fs.NArg() > 0 {
+		return fmt.Errorf("%w: validate takes a manifest with -f and no arguments", errUsage)
+	}
+	apps, err := c.read(*file)
+	if err != nil {
+		return err
+	}
+	validate := validator.New()
+	invalid := 0
+	for _, app := range apps {
+		err := validate.Struct(app)
+		if err == nil {
+			fmt.Fprintf(c.stdout, "application %q is valid\n", app.Title)
+			continue
+		}
+		invalid++
+		fmt.Fprintf(c.stdout, "application %q is invalid:\n", app.Title)
+		for _, err := range err.(validator.ValidationErrors) {
+			fmt.Fprintf(c.stdout, "  %s has invalid value %s\n", err.Namespace(), err.Value())
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d applications are invalid", invalid, len(apps))
+	}
+	return nil
+}
+
+// read decodes every application in a multi-document manifest, rejecting fields the server wouldn't accept.
+func (c *ctl) read(file string) ([]*types.ApplicationMetadata, error) {
+	var in io.Reader = c.stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	decoder := yaml.NewDecoder(in)
+	decoder.SetStrict(true)
+	apps := make([]*types.ApplicationMetadata, 0)
+	for {
+		var app *types.ApplicationMetadata
+		err := decoder.Decode(&app)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %v", file, len(apps)+1, err)
+		}
+		// Empty documents, such as after a trailing ---, decode to nil.
+		if app != nil {
+			apps = append(apps, app)
+		}
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("%s: no applications found", file)
+	}
+	return apps, nil
+}
+
+// print writes applications in the chosen format. Structured formats and templates see v, the applications as the
+// API serves them: a list from searches, a single application otherwise.
+func (c *ctl) print(apps []*types.ApplicationMetadata, v interface{}) error {
+	switch {
+	case c.output == "table":
+		w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TITLE\tVERSION\tCOMPANY\tLICENSE\tMAINTAINERS")
+		for _, app := range apps {
+			emails := make([]string, 0, len(app.Maintainers))
+			for _, m := range app.Maintainers {
+				emails = append(emails, m.Email)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", app.Title, app.Version, app.Company, app.License, strings.Join(emails, ","))
+		}
+		return w.Flush()
+	case c.output == "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = c.stdout.Write(data)
+		return err
+	case c.output == "json":
+		generic, err := fields(v)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(generic, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.stdout, "%s\n", data)
+		return err
+	case strings.HasPrefix(c.output, "go-template="):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(c.output, "go-template="))
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		generic, err := fields(v)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, generic); err != nil {
+			return err
+		}
+		_, err = c.stdout.Write(out.Bytes())
+		return err
+	default:
+		return fmt.Errorf("%w: unknown output format %s", errUsage, c.output)
+	}
+}
+
+// fields converts v to maps and slices keyed by its YAML field names, so JSON output and templates use the names
+// manifests do.
+func fields(v interface{}) (interface{}, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	err = yamlv3.Unmarshal(data, &generic)
+	return generic, err
+}
@@ -0,0 +1,283 @@
+// Package client calls the upbound API from Go, reusing the server's types so callers never build requests by hand.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Errors a request may fail with, match them with errors.Is. The *Error returned has the server's explanation.
+var (
+	ErrInvalid         = errors.New("invalid request")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooLarge        = errors.New("request too large")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrServer          = errors.New("server error")
+)
+
+// statusErrors maps response statuses to the errors they match.
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrInvalid,
+	http.StatusUnauthorized:          ErrUnauthenticated,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusTooManyRequests:       ErrRateLimited,
+}
+
+// Error is a request the server refused or failed.
+type Error struct {
+	StatusCode int
+	Message    string   // The server's explanation, e.g. "Failed to validate input of the following parameters:".
+	Details    []string // What the explanation lists, one invalid field or violated policy each.
+	RequestID  string   // Correlates the request with the server's logs.
+
+	retried bool // Whether an earlier attempt at the request failed, and so may have taken effect.
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("upbound responded %d: %s", e.StatusCode, e.Message)
+	if len(e.Details) > 0 {
+		msg += " " + strings.Join(e.Details, "; ")
+	}
+	return msg
+}
+
+// Is matches the error for the response status, or ErrServer for any 5xx.
+func (e *Error) Is(target error) bool {
+	if e.StatusCode >= 500 {
+		return target == ErrServer
+	}
+	return statusErrors[e.StatusCode] == target
+}
+
+// Client calls an upbound server, create it with New.
+type Client struct {
+	BaseURL     string        // Scheme, host and path prefix of the server, e.g. https://upbound.example.com.
+	Token       string        // Bearer token sent with every request, none when empty.
+	HTTPClient  *http.Client  // Give it a TLS configuration with a certificate to authenticate with one.
+	MaxAttempts int           // Attempts at each request, retrying server errors and rate limiting.
+	Backoff     time.Duration // Delay before the first retry, doubled on each subsequent one, unless the server asks for longer.
+}
+
+// New returns a client of the server at baseURL, authenticating with token unless it is empty.
+func New(baseURL string, token string) *Client {
+	return &Client{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		Token:       token,
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+		MaxAttempts: 4,
+		Backoff:     250 * time.Millisecond,
+	}
+}
+
+// Create stores a new application.
+func (c *Client) Create(ctx context.Context, app *types.ApplicationMetadata) error {
+	err := c.call(ctx, "PUT", "/create", nil, app, nil)
+	// An attempt which failed with a server error may still have stored the application, so a retry conflicting with
+	// it is the create succeeding, provided what is stored is what was sent.
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.retried && errors.Is(err, ErrConflict) {
+		if stored, getErr := c.Get(ctx, app.Title); getErr == nil && sameDocument(stored, app) {
+			return nil
+		}
+	}
+	return err
+}
+
+// sameDocument reports whether two applications serialize the same, ignoring the difference between empty and
+// missing lists which a round trip through the server loses.
+func sameDocument(a *types.ApplicationMetadata, b *types.ApplicationMetadata) bool {
+	dataA, errA := yaml.Marshal(a)
+	dataB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// Get fetches the application with the title.
+func (c *Client) Get(ctx context.Context, title string) (*types.ApplicationMetadata, error) {
+	app := &types.ApplicationMetadata{}
+	if err := c.call(ctx, "GET", "/get", url.Values{"title": {title}}, nil, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// Search returns the applications matching the query, fields left empty in it match anything.
+func (c *Client) Search(ctx context.Context, query *types.ApplicationMetadata) ([]*types.ApplicationMetadata, error) {
+	apps := make([]*types.ApplicationMetadata, 0)
+	if err := c.call(ctx, "POST", "/search", nil, query, &apps); err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// Update replaces the metadata of the application with the same title.
+func (c *Client) Update(ctx context.Context, app *types.ApplicationMetadata) error {
+	return c.call(ctx, "PUT", "/update", nil, app, nil)
+}
+
+// Delete removes the application with the title.
+func (c *Client) Delete(ctx context.Context, title string) error {
+	return c.call(ctx, "DELETE", "/delete", url.Values{"title": {title}}, nil, nil)
+}
+
+// Watch streams events of the application with the title, or of every application when the title is empty, until ctx
+// is done or the watcher is closed. The server ends streams when it shuts down or the client falls behind, callers
+// wanting every event should watch again when Next returns io.EOF, and reconcile with Get or Search.
+func (c *Client) Watch(ctx context.Context, title string) (*Watcher, error) {
+	query := url.Values{}
+	if title != "" {
+		query.Set("title", title)
+	}
+	// Streams outlive the client's timeout, so only ctx bounds them.
+	httpClient := *c.HTTPClient
+	httpClient.Timeout = 0
+	resp, err := c.do(ctx, &httpClient, "GET", "/watch", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{body: resp.Body, lines: bufio.NewReader(resp.Body)}, nil
+}
+
+// Watcher reads the events of a watch.
+type Watcher struct {
+	body  io.ReadCloser
+	lines *bufio.Reader
+}
+
+// Next blocks until the next event and returns it, or returns io.EOF once the server ends the stream.
+func (w *Watcher) Next() (*webhooks.Event, error) {
+	var doc bytes.Buffer
+	for {
+		line, err := w.lines.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && doc.Len() > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch line {
+		case "---\n":
+			doc.Reset()
+		case "...\n":
+			event := &webhooks.Event{}
+			if err := yaml.Unmarshal(doc.Bytes(), event); err != nil {
+				return nil, err
+			}
+			return event, nil
+		default:
+			doc.WriteString(line)
+		}
+	}
+}
+
+// Close ends the watch.
+func (w *Watcher) Close() error {
+	return w.body.Close()
+}
+
+// call sends the request and decodes a successful response into out, unless it is nil.
+func (c *Client) call(ctx context.Context, method string, path string, query url.Values, in interface{}, out interface{}) error {
+	resp, err := c.do(ctx, c.HTTPClient, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// do sends the request with in as its YAML body, unless it is nil, retrying server errors and rate limiting with
+// exponential backoff. Unsuccessful responses are returned as *Error.
+func (c *Client) do(ctx context.Context, httpClient *http.Client, method string, path string, query url.Values, in interface{}) (*http.Response, error) {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = yaml.Marshal(in); err != nil {
+			return nil, err
+		}
+	}
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/yaml")
+		}
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode < 300 {
+			return resp, nil
+		}
+		apiErr := readError(resp)
+		apiErr.retried = attempt > 1
+		if attempt >= c.MaxAttempts || (resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests) {
+			return nil, apiErr
+		}
+
+		delay := backoff
+		backoff *= 2
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && time.Duration(seconds)*time.Second > delay {
+			delay = time.Duration(seconds) * time.Second
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// readError consumes an unsuccessful response. The server explains failures in plain text, with one line per
+// problem following the first when there are several.
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: lines[0], RequestID: resp.Header.Get("X-Request-ID")}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			apiErr.Details = append(apiErr.Details, line)
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,449 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	validator "gopkg.in/go-playground/validator.v9"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EnvPrefix starts the name of every environment variable the configuration is read from.
+const EnvPrefix = "UPBOUND_"
+
+// DefaultMaxBodyBytes is the largest request body accepted unless configured otherwise.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Config is the complete server configuration. Settings are layered, each source overriding the ones before it:
+// built-in defaults, the YAML config file, UPBOUND_* environment variables, then command-line flags.
+// A setting's variable is derived from its YAML path, so limits.readRate is UPBOUND_LIMITS_READ_RATE, unless an env tag names it.
+type Config struct {
+	Listen    string    `yaml:"listen" validate:"required"`
+	GRPC      GRPC      `yaml:"grpc"`
+	Timeouts  Timeouts  `yaml:"timeouts"`
+	TLS       TLS       `yaml:"tls"`
+	Storage   Storage   `yaml:"storage"`
+	Auth      Auth      `yaml:"auth"`
+	Admission Admission `yaml:"admission"`
+	Limits    Limits    `yaml:"limits"`
+	Log       Log       `yaml:"log"`
+	Audit     Audit     `yaml:"audit"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Debug     Debug     `yaml:"debug"`
+
+	// Addresses or CIDRs of reverse proxies whose X-Forwarded-For is believed, so audit entries and limits see the client.
+	TrustedProxies []string `yaml:"trustedProxies" validate:"dive,cidr|ip"`
+}
+
+// Timeouts bound how long connections and shutdown may take.
+type Timeouts struct {
+	ReadHeader time.Duration `yaml:"readHeader" validate:"min=0"`
+	Read       time.Duration `yaml:"read" validate:"min=0"`
+	Write      time.Duration `yaml:"write" validate:"min=0"`
+	Idle       time.Duration `yaml:"idle" validate:"min=0"`
+	DrainDelay time.Duration `yaml:"drainDelay" validate:"min=0"` // How long to keep serving while failing readiness, so load balancers move traffic away first.
+	Shutdown   time.Duration `yaml:"shutdown" validate:"min=0"`   // How long in-flight requests and webhook deliveries may drain for.
+}
+
+// TLS configures serving HTTPS and verifying client certificates.
+type TLS struct {
+	Cert              string   `yaml:"cert"`
+	Key               string   `yaml:"key"`
+	ClientCA          string   `yaml:"clientCA"`
+	RequireClientCert bool     `yaml:"requireClientCert"`
+	ClientCertScopes  []string `yaml:"clientCertScopes" validate:"dive,oneof=read write admin"`
+}
+
+// Storage selects where applications are kept.
+type Storage struct {
+	Backend string `yaml:"backend" validate:"oneof=memory"`
+}
+
+// Auth configures how callers are authenticated.
+type Auth struct {
+	AdminToken     string   `yaml:"adminToken" env:"UPBOUND_ADMIN_TOKEN"` // Bootstraps an admin token, never set from a flag so it stays out of process listings.
+	AnonymousRead  bool     `yaml:"anonymousRead"`
+	JWKS           string   `yaml:"jwks"`
+	JWTIssuer      string   `yaml:"jwtIssuer"`
+	JWTAudience    string   `yaml:"jwtAudience"`
+	JWTEmailClaim  string   `yaml:"jwtEmailClaim" validate:"required"`
+	JWTGroupsClaim string   `yaml:"jwtGroupsClaim" validate:"required"`
+	JWTScopes      []string `yaml:"jwtScopes" validate:"dive,oneof=read write admin"` // Granted to every verified JWT caller.
+	JWTWriteGroups []string `yaml:"jwtWriteGroups"`
+	JWTAdminGroups []string `yaml:"jwtAdminGroups"`
+	// Use JWT emails without email_verified: true, only safe when the identity provider verifies every email.
+	JWTTrustUnverifiedEmail bool `yaml:"jwtTrustUnverifiedEmail"`
+}
+
+// Admission configures the hooks and policies applications must pass.
+type Admission struct {
+	DefaultLicense     string   `yaml:"defaultLicense"`
+	MutatingWebhooks   []string `yaml:"mutatingWebhooks" validate:"dive,url"`
+	ValidatingWebhooks []string `yaml:"validatingWebhooks" validate:"dive,url"`
+	Policy             string   `yaml:"policy"`
+}
+
+// Limits bounds what a single request or client may consume.
+type Limits struct {
+	MaxBodyBytes int64   `yaml:"maxBodyBytes" validate:"min=0"`
+	ReadRate     float64 `yaml:"readRate" validate:"min=0"`
+	ReadBurst    int     `yaml:"readBurst" validate:"min=1"`
+	WriteRate    float64 `yaml:"writeRate" validate:"min=0"`
+	WriteBurst   int     `yaml:"writeBurst" validate:"min=1"`
+	// Failed authentications per second allowed per client IP, after which even valid credentials are refused.
+	AuthFailureRate  float64 `yaml:"authFailureRate" validate:"min=0"`
+	AuthFailureBurst int     `yaml:"authFailureBurst" validate:"min=1"`
+}
+
+// Log configures the server's log output.
+type Log struct {
+	Level  string `yaml:"level" validate:"oneof=trace debug info warn error"` // The levels PUT /debug/loglevel accepts.
+	Format string `yaml:"format" validate:"oneof=text json"`
+	Output string `yaml:"output" validate:"required"` // stdout, stderr or a file path.
+	Access bool   `yaml:"access"`                     // Write a JSON access log line per request to the same output.
+}
+
+// Audit configures where the audit log is kept besides memory.
+type Audit struct {
+	File string `yaml:"file"` // Path entries are appended to as JSON lines and reloaded from at startup, - for stdout. Memory only when empty.
+}
+
+// Tracing configures where request traces are exported.
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" validate:"oneof=none stdout file otlp"`
+	File        string  `yaml:"file"`     // Path spans are written to with the file exporter.
+	Endpoint    string  `yaml:"endpoint"` // host:port of the OTLP collector, defaults to the OTEL_EXPORTER_OTLP_* environment variables.
+	Insecure    bool    `yaml:"insecure"` // Send OTLP over plain HTTP.
+	SampleRatio float64 `yaml:"sampleRatio" validate:"min=0,max=1"`
+	ServiceName string  `yaml:"serviceName" validate:"required"`
+}
+
+// GRPC configures the listener serving the gRPC API, which shares the HTTP API's TLS, authentication and limits.
+type GRPC struct {
+	Listen string `yaml:"listen"` // Address the gRPC API is served on, e.g. :9090. Off when empty.
+}
+
+// Debug configures the listener serving runtime profiles.
+type Debug struct {
+	Listen string `yaml:"listen"` // Address pprof is served on without authentication, e.g. localhost:6060. Off when empty.
+}
+
+// Default returns the configuration used when nothing is set.
+func Default() *Config {
+	return &Config{
+		Listen:   ":8080",
+		Timeouts: Timeouts{ReadHeader: 5 * time.Second, Read: 30 * time.Second, Write: 30 * time.Second, Idle: 2 * time.Minute, DrainDelay: 5 * time.Second, Shutdown: 20 * time.Second},
+		TLS:      TLS{ClientCertScopes: []string{auth.Write}},
+		Storage:  Storage{Backend: "memory"},
+		Auth:     Auth{JWTEmailClaim: "email", JWTGroupsClaim: "groups", JWTScopes: []string{auth.Read}},
+		Limits:   Limits{MaxBodyBytes: DefaultMaxBodyBytes, ReadRate: 20, ReadBurst: 40, WriteRate: 2, WriteBurst: 10, AuthFailureRate: 0.1, AuthFailureBurst: 10},
+		Log:      Log{Level: "info", Format: "text", Output: "stdout", Access: true},
+		Tracing:  Tracing{Exporter: "none", SampleRatio: 1, ServiceName: "upbound"},
+	}
+}
+
+// Options are the command-line flags which control loading rather than the configuration itself.
+type Options struct {
+	File        string
+	PrintConfig bool
+}
+
+// Load builds the configuration from the defaults, the config file, the environment and the command-line arguments,
+// and validates the result.
+func Load(name string, args []string) (*Config, Options, error) {
+	// The config file is named by a flag, so flags are parsed once to find it, then again over the file and environment.
+	var opts Options
+	if err := flags(name, Default(), &opts).Parse(args); err != nil {
+		return nil, opts, err
+	}
+	if opts.File == "" {
+		opts.File = os.Getenv(EnvPrefix + "CONFIG")
+	}
+
+	c := Default()
+	if opts.File != "" {
+		data, err := ioutil.ReadFile(opts.File)
+		if err != nil {
+			return nil, opts, err
+		}
+		if err := yaml.UnmarshalStrict(data, c); err != nil {
+			return nil, opts, fmt.Errorf("failed to parse config file %s: %v", opts.File, err)
+		}
+	}
+	if err := c.loadEnv(os.LookupEnv); err != nil {
+		return nil, opts, err
+	}
+	if err := flags(name, c, &opts).Parse(args); err != nil {
+		return nil, opts, err
+	}
+	return c, opts, c.Validate()
+}
+
+// flags binds the command-line flags to the configuration, so parsing only overwrites the settings given.
+func flags(name string, c *Config, opts *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&opts.File, "config", "", "Path to a YAML config file, also read from "+EnvPrefix+"CONFIG")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "Print the effective configuration, with secrets redacted, and exit")
+
+	fs.StringVar(&c.Listen, "listen", c.Listen, "Address to serve the API on")
+	fs.Var(&repeated{list: &c.TrustedProxies}, "trusted-proxy", "Address or CIDR of a reverse proxy whose X-Forwarded-For header is trusted (repeatable)")
+	fs.DurationVar(&c.Timeouts.ReadHeader, "read-header-timeout", c.Timeouts.ReadHeader, "Longest time to read a request's headers")
+	fs.DurationVar(&c.Timeouts.Read, "read-timeout", c.Timeouts.Read, "Longest time to read a whole request")
+	fs.DurationVar(&c.Timeouts.Write, "write-timeout", c.Timeouts.Write, "Longest time to write a response")
+	fs.DurationVar(&c.Timeouts.Idle, "idle-timeout", c.Timeouts.Idle, "Longest time an idle keep-alive connection stays open")
+	fs.DurationVar(&c.Timeouts.DrainDelay, "drain-delay", c.Timeouts.DrainDelay, "How long to keep serving with failing readiness after SIGTERM or SIGINT")
+	fs.DurationVar(&c.Timeouts.Shutdown, "shutdown-timeout", c.Timeouts.Shutdown, "Longest time to drain in-flight work once the drain delay has passed")
+	fs.StringVar(&c.TLS.Cert, "tls-cert", c.TLS.Cert, "PEM certificate to serve TLS with, reloaded when the file changes")
+	fs.StringVar(&c.TLS.Key, "tls-key", c.TLS.Key, "PEM private key matching -tls-cert")
+	fs.StringVar(&c.TLS.ClientCA, "client-ca", c.TLS.ClientCA, "PEM bundle of CAs client certificates are verified against")
+	fs.BoolVar(&c.TLS.RequireClientCert, "require-client-cert", c.TLS.RequireClientCert, "Refuse TLS connections without a verified client certificate")
+	fs.Var((*commaList)(&c.TLS.ClientCertScopes), "client-cert-scopes", "Comma separated scopes granted to callers identified by a client certificate")
+	fs.StringVar(&c.Storage.Backend, "storage", c.Storage.Backend, "Storage backend for applications, only memory is supported")
+	fs.BoolVar(&c.Auth.AnonymousRead, "anonymous-read", c.Auth.AnonymousRead, "Allow unauthenticated requests to read-only endpoints such as search")
+	fs.StringVar(&c.Auth.JWKS, "jwks", c.Auth.JWKS, "File path or URL of a JSON Web Key Set used to verify JWT bearer tokens")
+	fs.StringVar(&c.Auth.JWTIssuer, "jwt-issuer", c.Auth.JWTIssuer, "Required issuer of JWT bearer tokens")
+	fs.StringVar(&c.Auth.JWTAudience, "jwt-audience", c.Auth.JWTAudience, "Required audience of JWT bearer tokens")
+	fs.StringVar(&c.Auth.JWTEmailClaim, "jwt-email-claim", c.Auth.JWTEmailClaim, "JWT claim holding the caller's email")
+	fs.StringVar(&c.Auth.JWTGroupsClaim, "jwt-groups-claim", c.Auth.JWTGroupsClaim, "JWT claim holding the caller's groups")
+	fs.BoolVar(&c.Auth.JWTTrustUnverifiedEmail, "jwt-trust-unverified-email", c.Auth.JWTTrustUnverifiedEmail, "Use JWT emails without email_verified: true, only if the identity provider verifies every email")
+	fs.Var((*commaList)(&c.Auth.JWTScopes), "jwt-scopes", "Comma separated scopes granted to every caller identified by a JWT")
+	fs.Var(&repeated{list: &c.Auth.JWTWriteGroups}, "jwt-write-group", "JWT group whose members are granted the write scope (repeatable)")
+	fs.Var(&repeated{list: &c.Auth.JWTAdminGroups}, "jwt-admin-group", "JWT group whose members are granted the admin scope (repeatable)")
+	fs.StringVar(&c.Admission.DefaultLicense, "default-license", c.Admission.DefaultLicense, "License injected into applications which do not specify one")
+	fs.Var(&repeated{list: &c.Admission.MutatingWebhooks}, "mutating-webhook", "URL of an external admission endpoint allowed to patch applications (repeatable)")
+	fs.Var(&repeated{list: &c.Admission.ValidatingWebhooks}, "validating-webhook", "URL of an external admission endpoint allowed to reject applications (repeatable)")
+	fs.StringVar(&c.Admission.Policy, "policy", c.Admission.Policy, "Path to a YAML file of CEL policy rules applications must satisfy")
+	fs.Int64Var(&c.Limits.MaxBodyBytes, "max-body-bytes", c.Limits.MaxBodyBytes, "Largest request body accepted, larger ones get 413")
+	fs.Float64Var(&c.Limits.ReadRate, "read-rate", c.Limits.ReadRate, "Read requests per second allowed per client, 0 for unlimited")
+	fs.IntVar(&c.Limits.ReadBurst, "read-burst", c.Limits.ReadBurst, "Read requests a client may make in a burst")
+	fs.Float64Var(&c.Limits.WriteRate, "write-rate", c.Limits.WriteRate, "Write requests per second allowed per client, 0 for unlimited")
+	fs.IntVar(&c.Limits.WriteBurst, "write-burst", c.Limits.WriteBurst, "Write requests a client may make in a burst")
+	fs.Float64Var(&c.Limits.AuthFailureRate, "auth-failure-rate", c.Limits.AuthFailureRate, "Failed authentications per second allowed per client IP, 0 for unlimited")
+	fs.IntVar(&c.Limits.AuthFailureBurst, "auth-failure-burst", c.Limits.AuthFailureBurst, "Failed authentications a client IP may make in a burst")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "Minimum level logged: trace, debug, info, warn or error")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "Log format: text or json")
+	fs.StringVar(&c.Log.Output, "log-output", c.Log.Output, "Where logs go: stdout, stderr or a file path")
+	fs.BoolVar(&c.Log.Access, "access-log", c.Log.Access, "Write a JSON access log line per request")
+	fs.StringVar(&c.Audit.File, "audit-file", c.Audit.File, "File the audit log is appended to and reloaded from, - for stdout")
+	fs.StringVar(&c.Tracing.Exporter, "trace-exporter", c.Tracing.Exporter, "Where traces go: none, stdout, file or otlp")
+	fs.StringVar(&c.Tracing.File, "trace-file", c.Tracing.File, "File traces are written to with the file exporter")
+	fs.StringVar(&c.Tracing.Endpoint, "trace-endpoint", c.Tracing.Endpoint, "host:port of the OTLP collector traces are sent to")
+	fs.BoolVar(&c.Tracing.Insecure, "trace-insecure", c.Tracing.Insecure, "Send traces to the OTLP collector over plain HTTP")
+	fs.Float64Var(&c.Tracing.SampleRatio, "trace-sample-ratio", c.Tracing.SampleRatio, "Fraction of new requests traced, requests already traced upstream follow the caller")
+	fs.StringVar(&c.GRPC.Listen, "grpc-listen", c.GRPC.Listen, "Address to serve the gRPC API on, e.g. :9090")
+	fs.StringVar(&c.Debug.Listen, "debug-listen", c.Debug.Listen, "Address to serve pprof on without authentication, e.g. localhost:6060")
+	return fs
+}
+
+// Validate checks the settings, including the constraints between them.
+func (c *Config) Validate() error {
+	if err := validator.New().Struct(c); err != nil {
+		fields := make([]string, 0)
+		for _, err := range err.(validator.ValidationErrors) {
+			fields = append(fields, fmt.Sprintf("%s has invalid value %v", err.Namespace(), err.Value()))
+		}
+		return fmt.Errorf("invalid configuration: %s", strings.Join(fields, "; "))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return errors.New("invalid configuration: tls.cert and tls.key must be set together")
+	}
+	if c.TLS.ClientCA != "" && c.TLS.Cert == "" {
+		return errors.New("invalid configuration: tls.clientCA requires serving TLS")
+	}
+	if c.TLS.RequireClientCert && c.TLS.ClientCA == "" {
+		return errors.New("invalid configuration: tls.requireClientCert needs tls.clientCA to verify certificates against")
+	}
+	if c.Auth.JWKS != "" && c.Auth.JWTAudience == "" {
+		return errors.New("invalid configuration: auth.jwks needs auth.jwtAudience, so tokens issued to other clients are refused")
+	}
+	if c.GRPC.Listen != "" && (c.GRPC.Listen == c.Listen || c.GRPC.Listen == c.Debug.Listen) {
+		return errors.New("invalid configuration: grpc.listen must differ from listen and debug.listen")
+	}
+	if c.Debug.Listen != "" && c.Debug.Listen == c.Listen {
+		return errors.New("invalid configuration: debug.listen must differ from listen, profiles are served without authentication")
+	}
+	if (c.Tracing.Exporter == "file") != (c.Tracing.File != "") {
+		return errors.New("invalid configuration: tracing.file must be set for, and only for, the file exporter")
+	}
+	return nil
+}
+
+// Redacted returns a copy safe to print, with secrets masked.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Auth.AdminToken != "" {
+		redacted.Auth.AdminToken = "REDACTED"
+	}
+	return &redacted
+}
+
+// SetupLogging applies the log settings to the standard logger.
+func (c *Config) SetupLogging() error {
+	level, err := log.ParseLevel(c.Log.Level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(level)
+	if c.Log.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	var out io.Writer
+	switch c.Log.Output {
+	case "stdout":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		f, err := os.OpenFile(c.Log.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	log.SetOutput(out)
+	if !c.Log.Access {
+		out = ioutil.Discard
+	}
+	logging.Access.SetOutput(out)
+	return nil
+}
+
+// loadEnv overrides settings from the environment, looked up through lookup so tests can supply their own.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	return setEnv(reflect.ValueOf(c).Elem(), EnvPrefix, lookup)
+}
+
+func setEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := prefix + envName(strings.Split(f.Tag.Get("yaml"), ",")[0])
+		if f.Type.Kind() == reflect.Struct {
+			if err := setEnv(v.Field(i), name+"_", lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag := f.Tag.Get("env"); tag != "" {
+			name = tag
+		}
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value %q of %s: %v", value, name, err)
+		}
+	}
+	return nil
+}
+
+// envName turns a camelCase key into SCREAMING_SNAKE_CASE, keeping initialisms such as CA together.
+func envName(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		v.Set(reflect.ValueOf(split(s)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func split(s string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// commaList is a flag holding a comma separated list.
+type commaList []string
+
+func (l *commaList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	*l = split(value)
+	return nil
+}
+
+// repeated is a flag collecting every occurrence, replacing rather than extending lists set by lower precedence sources.
+type repeated struct {
+	list *[]string
+	set  bool
+}
+
+func (r *repeated) String() string {
+	if r == nil || r.list == nil {
+		return ""
+	}
+	return strings.Join(*r.list, ",")
+}
+
+func (r *repeated) Set(value string) error {
+	if !r.set {
+		*r.list = nil
+		r.set = true
+	}
+	*r.list = append(*r.list, value)
+	return nil
+}
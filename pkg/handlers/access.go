@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID correlating a request's log lines, honored from callers and echoed in responses.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds caller supplied IDs, so they can't bloat every log line.
+const maxRequestIDLength = 128
+
+// accessLog tags each request to the route with an ID, taken from the X-Request-ID header when the caller sent a
+// usable one, gives handlers a log entry carrying it, and writes an access log line once the request is handled.
+func accessLog(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = util.NewID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		entry := log.WithFields(log.Fields{"request_id": id})
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			entry = entry.WithFields(log.Fields{"trace_id": span.TraceID().String()})
+		}
+		ctx := logging.NewContext(r.Context(), entry)
+		recorder := util.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		fields := log.Fields{
+			"request_id": id,
+			"method":     r.Method,
+			"route":      route,
+			"path":       r.URL.Path,
+			"status":     recorder.Status,
+			"bytes":      recorder.Bytes,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"remote":     r.RemoteAddr,
+			"identity":   "anonymous",
+		}
+		// Identity and result counts are added by the authentication middleware and handlers further in.
+		for k, v := range logging.Fields(ctx) {
+			fields[k] = v
+		}
+		logging.Access.WithFields(fields).Info("Request handled")
+	})
+}
+
+// validRequestID reports whether a caller supplied request ID is short and printable enough to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
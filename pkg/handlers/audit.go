@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/types"
+)
+
+// Audit returns audit log entries filtered by the title, actor (e.g. token:ci), email and since (RFC 3339) query
+// parameters.
+func (srv *Server) Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to query the audit log.", http.StatusBadRequest)
+		return
+	}
+	query := audit.Query{Title: r.URL.Query().Get("title"), Actor: r.URL.Query().Get("actor"), Email: r.URL.Query().Get("email")}
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			http.Error(w, "Please provide since as an RFC 3339 timestamp, e.g. 2019-01-02T15:04:05Z.", http.StatusBadRequest)
+			return
+		}
+		query.Since = t
+	}
+	srv.respond(w, srv.AuditLog.Find(query))
+}
+
+// AuditVerify checks the audit log's hash chain is intact.
+func (srv *Server) AuditVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to verify the audit log.", http.StatusBadRequest)
+		return
+	}
+	if err := srv.AuditLog.Verify(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("Audit log is intact."))
+}
+
+// auditTarget records a mutation of a non-application resource such as a token or role binding, once it was made.
+// Should the log fail to record it, the log refuses every later entry and auditing refuses the changes they would
+// have recorded.
+func (srv *Server) auditTarget(r *http.Request, entry audit.Entry) {
+	srv.auditFrom(r.Context(), r.RemoteAddr, entry)
+}
+
+// auditing refuses changes through next while the audit log can't record them, leaving reads alone.
+func (srv *Server) auditing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := srv.AuditLog.Err(); err != nil && r.Method != "GET" {
+			srv.fail(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// change describes an application mutation, diffing the versions before and after it.
+func change(action string, before *types.ApplicationMetadata, after *types.ApplicationMetadata) audit.Entry {
+	entry := audit.Entry{Action: action, Changes: audit.Diff(before, after)}
+	if after != nil {
+		entry.Title = after.Title
+	} else if before != nil {
+		entry.Title = before.Title
+	}
+	return entry
+}
+
+// auditFrom records an entry by the caller in ctx, connecting from the remote address, failing with a
+// *audit.WriteError when the log can't be written.
+func (srv *Server) auditFrom(ctx context.Context, remote string, entry audit.Entry) error {
+	entry.Actor = "anonymous"
+	if id := auth.FromContext(ctx); id != nil {
+		// Principals are qualified by source, so a token and a certificate with the same name are told apart.
+		entry.Actor = id.Principal()
+		entry.Email = id.Email
+	}
+	entry.IP = remote
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		entry.IP = host
+	}
+	entry.RequestID = logging.RequestID(ctx)
+	_, err := srv.AuditLog.Record(entry)
+	return err
+}
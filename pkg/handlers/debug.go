@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// DebugHeader asks for a search to explain itself, by attaching the comparison trace of every candidate to the response.
+const DebugHeader = "X-Debug"
+
+// logLevel is the body of the log level endpoint, accepting the levels log.level can be configured with.
+type logLevel struct {
+	Level string `yaml:"level" validate:"required,oneof=trace debug info warn error"`
+}
+
+// LogLevel reports (GET) or changes (PUT) the level of the server's log at runtime.
+func (srv *Server) LogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		srv.respond(w, logLevel{Level: log.GetLevel().String()})
+	case "PUT":
+		req := &logLevel{}
+		if !srv.parse(w, r, req) {
+			return
+		}
+		if !srv.validate(w, r, req) {
+			return
+		}
+		level, _ := log.ParseLevel(req.Level)
+		previous := log.GetLevel()
+		log.SetLevel(level)
+		logging.FromContext(r.Context()).WithFields(log.Fields{"from": previous.String(), "to": level.String()}).Info("Log level changed")
+		srv.auditTarget(r, audit.Entry{Action: "loglevel.set", Target: level.String()})
+		srv.respond(w, logLevel{Level: level.String()})
+	default:
+		http.Error(w, "Please use a GET or PUT request to manage the log level.", http.StatusBadRequest)
+	}
+}
+
+// debugging reports whether the request asked for a search trace.
+func debugging(r *http.Request) bool {
+	debug, _ := strconv.ParseBool(r.Header.Get(DebugHeader))
+	return debug
+}
+
+// searchResponse is the response to an explained or debugged search: the matches as usual, and why each application
+// did or didn't match.
+type searchResponse struct {
+	Matches      []*types.ApplicationMetadata `yaml:"matches"`
+	Explanations []*util.Explanation          `yaml:"explanations,omitempty"`
+	Trace        []candidateTrace             `yaml:"trace,omitempty"`
+}
+
+// candidateTrace is the comparison of one application against the query.
+type candidateTrace struct {
+	Title   string         `yaml:"title"`
+	Matched bool           `yaml:"matched"`
+	Steps   []logging.Step `yaml:"steps"`
+}
+
+// candidateTraces groups the debug lines logged while filtering by the candidate they compared.
+func candidateTraces(steps []logging.Step) []candidateTrace {
+	traces := make([]candidateTrace, 0)
+	index := make(map[string]int)
+	for _, step := range steps {
+		title, ok := step.Fields["candidate"].(string)
+		if !ok {
+			continue
+		}
+		i, seen := index[title]
+		if !seen {
+			i = len(traces)
+			index[title] = i
+			traces = append(traces, candidateTrace{Title: title, Steps: make([]logging.Step, 0)})
+		}
+		if matched, ok := step.Fields["matched"].(bool); ok {
+			traces[i].Matched = matched
+			continue
+		}
+		// Drop the fields every line shares, leaving what was compared.
+		for _, k := range []string{"candidate", "request_id", "trace_id", "identity"} {
+			delete(step.Fields, k)
+		}
+		traces[i].Steps = append(traces[i].Steps, step)
+	}
+	return traces
+}
+
+// Pprof serves the runtime profiles under /debug/pprof/. It has no authentication, so it is only served on a separate
+// debug listener which should not be reachable from outside the pod.
+func Pprof() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
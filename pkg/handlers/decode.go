@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// errAliases is returned for documents using anchors or aliases, which no payload needs and which
+// let tiny documents expand into huge ones.
+var errAliases = errors.New("YAML anchors and aliases are not supported")
+
+// limitBody caps the size of request bodies, reads past the limit fail with *http.MaxBytesError.
+func (srv *Server) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv.MaxBodyBytes > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, srv.MaxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// parse reads the request body into v, writing an error response and returning false on failure.
+func (srv *Server) parse(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	_, span := tracing.Start(r.Context(), "read body")
+	body, err := ioutil.ReadAll(r.Body)
+	span.SetAttributes(attribute.Int("bytes", len(body)))
+	span.End()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body is too large, the limit is "+formatBytes(tooLarge.Limit)+".", http.StatusRequestEntityTooLarge)
+			logging.FromContext(r.Context()).Info("Rejected oversized request body")
+			return false
+		}
+		http.Error(w, "Failed to read body of request", http.StatusInternalServerError)
+		return false
+	}
+	_, span = tracing.Start(r.Context(), "decode")
+	err = unmarshal(r, body, v)
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	span.End()
+	switch err := err.(type) {
+	case nil:
+		return true
+	case unknownFields:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Failed to parse input, the following fields are unknown:\n"))
+		for _, f := range err {
+			fmt.Fprintln(w, f)
+		}
+		logging.FromContext(r.Context()).WithFields(log.Fields{"fields": err.Error()}).Info("Rejected unknown fields")
+	default:
+		if err == errAliases {
+			http.Error(w, "Failed to parse YAML input: "+err.Error()+".", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to parse YAML input. This likely indicates malformed request body. Verify the payload fields and parameter types are correct.", http.StatusBadRequest)
+		}
+		logging.FromContext(r.Context()).Info("YAML parse error")
+	}
+	return false
+}
+
+// decode reads the request body into application metadata, writing an error response and returning false on failure.
+func (srv *Server) decode(w http.ResponseWriter, r *http.Request) (*types.ApplicationMetadata, bool) {
+	metadata := &types.ApplicationMetadata{}
+	if !srv.parse(w, r, metadata) {
+		return nil, false
+	}
+	return metadata, true
+}
+
+// unmarshal strictly parses the body into v, as JSON if the request says so and YAML otherwise. Keys which match no
+// field are reported as unknownFields rather than silently dropped, since a misspelled search key would match everything.
+// YAML anchors and aliases are refused before anything is expanded.
+func unmarshal(r *http.Request, data []byte, v interface{}) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/json" {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(v)
+		if key := strings.TrimPrefix(fmt.Sprint(err), "json: unknown field "); err != nil && key != err.Error() {
+			key, _ = strconv.Unquote(key)
+			return unknownFields{{Key: key, Suggestion: util.Nearest(key, fieldNames(reflect.TypeOf(v)))}}
+		}
+		return err
+	}
+
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if aliased(&doc) {
+		return errAliases
+	}
+	if len(doc.Content) > 0 {
+		unknown := unknownFields{}
+		unknown.find(doc.Content[0], reflect.TypeOf(v), "")
+		if len(unknown) > 0 {
+			return unknown
+		}
+	}
+	return yaml.UnmarshalStrict(data, v)
+}
+
+// unknownField is a key in a submitted document which matches no field, with the field the submitter likely meant.
+type unknownField struct {
+	Key        string
+	Line       int // Zero for JSON, where the decoder doesn't say.
+	Suggestion string
+}
+
+func (f unknownField) String() string {
+	s := f.Key
+	if f.Line > 0 {
+		s += fmt.Sprintf(" on line %d", f.Line)
+	}
+	if f.Suggestion != "" {
+		s += ", did you mean " + f.Suggestion + "?"
+	}
+	return s
+}
+
+type unknownFields []unknownField
+
+func (u unknownFields) Error() string {
+	keys := make([]string, 0, len(u))
+	for _, f := range u {
+		keys = append(keys, f.String())
+	}
+	return "unknown fields: " + strings.Join(keys, "; ")
+}
+
+// find walks the document alongside the type it is decoded into, collecting keys of mappings which match no struct field.
+// Nodes whose shape doesn't match the type are left for the decoder to report.
+func (u *unknownFields) find(n *yamlv3.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yamlv3.MappingNode:
+		fields := yamlFields(t)
+		names := fieldNames(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if ft, ok := fields[key.Value]; ok {
+				u.find(value, ft, path+key.Value+".")
+			} else {
+				*u = append(*u, unknownField{Key: path + key.Value, Line: key.Line, Suggestion: util.Nearest(key.Value, names)})
+			}
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && n.Kind == yamlv3.SequenceNode:
+		prefix := strings.TrimSuffix(path, ".")
+		for i, item := range n.Content {
+			u.find(item, t.Elem(), fmt.Sprintf("%s[%d].", prefix, i))
+		}
+	}
+}
+
+// yamlFields maps the keys yaml.v2 decodes into a struct to the types of their fields.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		switch {
+		case tag[0] == "-":
+		case len(tag) > 1 && tag[1] == "inline":
+			for key, ft := range yamlFields(f.Type) {
+				fields[key] = ft
+			}
+		case tag[0] != "":
+			fields[tag[0]] = f.Type
+		default:
+			fields[strings.ToLower(f.Name)] = f.Type
+		}
+	}
+	return fields
+}
+
+// fieldNames lists the keys a type decodes from in a stable order, so suggestions are deterministic.
+func fieldNames(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	names := make([]string, 0)
+	for name := range yamlFields(t) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func aliased(n *yamlv3.Node) bool {
+	if n.Kind == yamlv3.AliasNode || n.Anchor != "" {
+		return true
+	}
+	for _, c := range n.Content {
+		if aliased(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// peek parses the request body into v, leaving the body intact for the handler. Parse errors are left to the handler
+// to report, so read errors are replayed after the data read before them.
+func peek(r *http.Request, v interface{}) bool {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), failingReader{err}))
+		return false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return unmarshal(r, body, v) == nil
+}
+
+type failingReader struct{ err error }
+
+func (f failingReader) Read([]byte) (int, error) { return 0, f.err }
+
+func formatBytes(n int64) string {
+	units := []string{"bytes", "KiB", "MiB", "GiB"}
+	i := 0
+	for n >= 1024 && n%1024 == 0 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return strconv.FormatInt(n, 10) + " " + units[i]
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/admission"
+	upboundv1 "github.com/alexeldeib/upbound/pkg/api/upbound/v1"
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/ratelimit"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcActions maps the registry's methods to the action they perform, like the Action of HTTP routes.
+var grpcActions = map[string]string{
+	upboundv1.Registry_Create_FullMethodName: rbac.Write,
+	upboundv1.Registry_Get_FullMethodName:    rbac.Read,
+	upboundv1.Registry_List_FullMethodName:   rbac.Read,
+	upboundv1.Registry_Search_FullMethodName: rbac.Read,
+	upboundv1.Registry_Update_FullMethodName: rbac.Write,
+	upboundv1.Registry_Delete_FullMethodName: rbac.Write,
+	upboundv1.Registry_Watch_FullMethodName:  rbac.Read,
+}
+
+// GRPC returns a gRPC server of the registry, see pkg/api/upbound/v1/registry.proto. It shares the store, validation,
+// admission hooks, policy, authentication, role bindings, rate limits, audit log and webhooks of the HTTP API. Callers
+// authenticate with a bearer token in the authorization metadata, or a client certificate when tlsConfig verifies
+// them; the server is plaintext when tlsConfig is nil.
+func (srv *Server) GRPC(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(srv.unary), grpc.StreamInterceptor(srv.stream)}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if srv.MaxBodyBytes > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(srv.MaxBodyBytes)))
+	}
+	s := grpc.NewServer(opts...)
+	upboundv1.RegisterRegistryServer(s, &registry{srv: srv})
+	return s
+}
+
+// unary runs a unary call the way Handler runs a request to a route.
+func (srv *Server) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	start := time.Now()
+	ctx, span := tracing.StartCall(ctx, info.FullMethod)
+	ctx, id := srv.begin(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+	defer func() {
+		srv.end(ctx, info.FullMethod, id, start, err)
+		tracing.EndCall(span, err)
+	}()
+	defer srv.recovered(ctx, info.FullMethod, &err)
+	if ctx, err = srv.admitCall(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// stream runs a streaming call the way Handler runs a request to a route.
+func (srv *Server) stream(s interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx, span := tracing.StartCall(ss.Context(), info.FullMethod)
+	ctx, id := srv.begin(ctx)
+	ss.SetHeader(metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+	defer func() {
+		srv.end(ctx, info.FullMethod, id, start, err)
+		tracing.EndCall(span, err)
+	}()
+	defer srv.recovered(ctx, info.FullMethod, &err)
+	if ctx, err = srv.admitCall(ctx, info.FullMethod); err != nil {
+		return err
+	}
+	return handler(s, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// recovered turns a panic in a call into an Internal error, as net/http recovers a panicking handler, so one call
+// can't take down the server. It must be deferred.
+func (srv *Server) recovered(ctx context.Context, method string, err *error) {
+	if p := recover(); p != nil {
+		logging.FromContext(ctx).WithFields(log.Fields{"method": method, "panic": p, "stack": string(debug.Stack())}).Error("Recovered from a panic")
+		*err = status.Error(codes.Internal, "The server failed to handle the call. This is likely a server error.")
+	}
+}
+
+// serverStream overrides the context of a stream, to hand the call's logger and identity to the method.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// begin tags the call with an ID, taken from the x-request-id metadata when the caller sent a usable one, and gives
+// the call a log entry carrying it, like accessLog.
+func (srv *Server) begin(ctx context.Context) (context.Context, string) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDHeader); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if !validRequestID(id) {
+		id = util.NewID()
+	}
+	entry := log.WithFields(log.Fields{"request_id": id})
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		entry = entry.WithFields(log.Fields{"trace_id": span.TraceID().String()})
+	}
+	return logging.NewContext(ctx, entry), id
+}
+
+// end writes the access log line of a call and records its metrics.
+func (srv *Server) end(ctx context.Context, method string, id string, start time.Time, err error) {
+	label := method
+	if _, ok := grpcActions[method]; !ok {
+		label = "other"
+	}
+	srv.Metrics.ObserveCall(label, status.Code(err).String(), time.Since(start))
+	fields := log.Fields{
+		"request_id": id,
+		"method":     "gRPC",
+		"route":      method,
+		"status":     status.Code(err).String(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"remote":     srv.remote(ctx),
+		"identity":   "anonymous",
+	}
+	for k, v := range logging.Fields(ctx) {
+		fields[k] = v
+	}
+	logging.Access.WithFields(fields).Info("Request handled")
+}
+
+// admitCall authenticates the caller, takes a token from their rate limit and checks their scopes and roles allow
+// the method's action, returning a context carrying their identity. Writes are further authorized by the method
+// through authorizeWrite, once the application they target is known.
+func (srv *Server) admitCall(ctx context.Context, method string) (context.Context, error) {
+	action, ok := grpcActions[method]
+	if !ok {
+		return ctx, status.Errorf(codes.Unimplemented, "Unknown method %s.", method)
+	}
+
+	secret := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			secret = strings.TrimSpace(strings.TrimPrefix(values[0], "Bearer "))
+		}
+	}
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+	ip := ratelimit.ClientIP(srv.remote(ctx))
+	if srv.Limiter != nil && !srv.Limiter.Check(ip, ratelimit.Failed).Allowed {
+		logging.FromContext(ctx).WithFields(log.Fields{"client": ip, "class": ratelimit.Failed}).Info("Rate limited request")
+		return ctx, status.Error(codes.ResourceExhausted, "Too many failed authentications, please retry later.")
+	}
+	id, err := srv.Auth.Identify(secret, state)
+	var refused error
+	switch {
+	case err != nil:
+		refused = status.Error(codes.Unauthenticated, "Authentication failed: "+err.Error())
+	case id == nil && !(action == rbac.Read && srv.Auth.AnonymousRead):
+		refused = status.Error(codes.Unauthenticated, "Please provide a bearer token in the authorization metadata.")
+	}
+	if refused != nil {
+		if srv.Limiter != nil {
+			srv.Limiter.Allow(ip, ratelimit.Failed)
+		}
+		return ctx, refused
+	}
+	if id != nil {
+		logging.AddFields(ctx, log.Fields{"identity": id.Subject})
+		ctx = auth.NewContext(ctx, id)
+	}
+
+	if srv.Limiter != nil {
+		client := ip
+		if id != nil {
+			client = "id:" + id.Principal()
+		}
+		class := ratelimit.Write
+		if action == rbac.Read {
+			class = ratelimit.Read
+		}
+		if d := srv.Limiter.Allow(client, class); !d.Allowed {
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int((d.RetryAfter+time.Second-1)/time.Second))))
+			logging.FromContext(ctx).WithFields(log.Fields{"client": client, "class": class}).Info("Rate limited request")
+			return ctx, status.Error(codes.ResourceExhausted, "Too many requests, please retry later.")
+		}
+	}
+
+	if id == nil {
+		// Only anonymous reads make it past authentication.
+		return ctx, nil
+	}
+	if scope := rbac.Scope(action); !id.Allows(scope) {
+		logging.FromContext(ctx).WithFields(log.Fields{"subject": id.Subject, "scope": scope, "method": method}).Info("Forbidden request")
+		return ctx, status.Error(codes.PermissionDenied, "This identity lacks the "+scope+" scope required for this request.")
+	}
+	if action == rbac.Read {
+		if reason := srv.RBAC.Decide(id, action, nil, nil); reason != "" {
+			logging.FromContext(ctx).WithFields(log.Fields{"subject": id.Subject, "method": method, "reason": reason}).Info("Forbidden request")
+			return ctx, status.Error(codes.PermissionDenied, reason)
+		}
+	}
+	return ctx, nil
+}
+
+// authorizeWrite checks the caller's roles allow a write to the stored and submitted applications, like the
+// resolvers of HTTP routes.
+func (srv *Server) authorizeWrite(ctx context.Context, existing *types.ApplicationMetadata, proposed *types.ApplicationMetadata) error {
+	id := auth.FromContext(ctx)
+	if reason := srv.RBAC.Decide(id, rbac.Write, existing, proposed); reason != "" {
+		logging.FromContext(ctx).WithFields(log.Fields{"subject": id.Subject, "reason": reason}).Info("Forbidden request")
+		return status.Error(codes.PermissionDenied, reason)
+	}
+	return nil
+}
+
+// remote returns the address of the caller, taken from the x-forwarded-for metadata when a trusted proxy relayed it.
+func (srv *Server) remote(ctx context.Context) string {
+	addr := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if len(srv.TrustedProxies) == 0 {
+		return addr
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return srv.clientAddr(addr, md.Get(ForwardedForHeader))
+}
+
+// grpcError maps the errors of operations on applications to gRPC statuses, as fail does to HTTP responses.
+func grpcError(err error) error {
+	switch e := err.(type) {
+	case *InvalidError:
+		st := status.New(codes.InvalidArgument, err.Error())
+		violations := &errdetails.BadRequest{}
+		for _, field := range e.Fields {
+			violations.FieldViolations = append(violations.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Namespace(),
+				Description: fmt.Sprintf("has invalid value %s", field.Value()),
+			})
+		}
+		if detailed, err := st.WithDetails(violations); err == nil {
+			return detailed.Err()
+		}
+		return st.Err()
+	case *PolicyError, *admission.Rejection:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case *ConflictError:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case *NotFoundError:
+		return status.Error(codes.NotFound, err.Error())
+	case *admission.Failure, *audit.WriteError:
+		// Only blame the server when a hook could not be consulted or the change could not be recorded.
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// registry implements the gRPC API on top of the operations the HTTP handlers use.
+type registry struct {
+	upboundv1.UnimplementedRegistryServer
+	srv *Server
+}
+
+func (g *registry) Create(ctx context.Context, req *upboundv1.CreateRequest) (*upboundv1.Application, error) {
+	metadata := fromProto(req.GetApplication())
+	if err := g.srv.authorizeWrite(ctx, nil, metadata); err != nil {
+		return nil, err
+	}
+	if err := g.srv.createApplication(ctx, g.srv.remote(ctx), metadata); err != nil {
+		return nil, grpcError(err)
+	}
+	return toProto(metadata), nil
+}
+
+func (g *registry) Get(ctx context.Context, req *upboundv1.GetRequest) (*upboundv1.Application, error) {
+	if req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Please provide the title of the application.")
+	}
+	metadata := g.srv.lookup(ctx, req.GetTitle())
+	if metadata == nil {
+		return nil, grpcError(&NotFoundError{Title: req.GetTitle()})
+	}
+	return toProto(metadata), nil
+}
+
+func (g *registry) List(ctx context.Context, req *upboundv1.ListRequest) (*upboundv1.ListResponse, error) {
+	g.srv.mu.RLock()
+	apps := make([]*upboundv1.Application, 0, len(g.srv.Applications))
+	for _, metadata := range g.srv.Applications {
+		apps = append(apps, toProto(metadata))
+	}
+	g.srv.mu.RUnlock()
+	return &upboundv1.ListResponse{Applications: apps}, nil
+}
+
+func (g *registry) Search(ctx context.Context, req *upboundv1.SearchRequest) (*upboundv1.SearchResponse, error) {
+	// Queries aren't validated, fields left empty match anything.
+	matches := g.srv.search(ctx, ctx, fromProto(req.GetQuery()), util.Compare)
+	apps := make([]*upboundv1.Application, 0, len(matches))
+	for _, metadata := range matches {
+		apps = append(apps, toProto(metadata))
+	}
+	return &upboundv1.SearchResponse{Applications: apps}, nil
+}
+
+func (g *registry) Update(ctx context.Context, req *upboundv1.UpdateRequest) (*upboundv1.Application, error) {
+	metadata := fromProto(req.GetApplication())
+	if err := g.srv.authorizeWrite(ctx, g.srv.lookup(ctx, metadata.Title), metadata); err != nil {
+		return nil, err
+	}
+	if err := g.srv.updateApplication(ctx, g.srv.remote(ctx), metadata); err != nil {
+		return nil, grpcError(err)
+	}
+	return toProto(metadata), nil
+}
+
+func (g *registry) Delete(ctx context.Context, req *upboundv1.DeleteRequest) (*upboundv1.DeleteResponse, error) {
+	if req.GetTitle() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Please provide the title of the application.")
+	}
+	if err := g.srv.authorizeWrite(ctx, g.srv.lookup(ctx, req.GetTitle()), nil); err != nil {
+		return nil, err
+	}
+	if err := g.srv.deleteApplication(ctx, g.srv.remote(ctx), req.GetTitle()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &upboundv1.DeleteResponse{}, nil
+}
+
+// Watch streams events like the /watch route, ending when the caller goes away, falls too far behind, or the server
+// shuts down, after which callers should watch again.
+func (g *registry) Watch(req *upboundv1.WatchRequest, stream upboundv1.Registry_WatchServer) error {
+	events := g.srv.Webhooks.Watch(stream.Context(), req.GetTitle())
+	// Send the headers right away, so callers know once the watch is registered.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for event := range events {
+		if err := stream.Send(eventProto(event)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventTypes maps webhook event types to their gRPC counterparts.
+var eventTypes = map[string]upboundv1.Event_Type{
+	webhooks.Created: upboundv1.Event_TYPE_CREATED,
+	webhooks.Updated: upboundv1.Event_TYPE_UPDATED,
+	webhooks.Deleted: upboundv1.Event_TYPE_DELETED,
+}
+
+func eventProto(event *webhooks.Event) *upboundv1.Event {
+	return &upboundv1.Event{
+		Id:          event.ID,
+		Type:        eventTypes[event.Type],
+		Time:        timestamppb.New(event.Time),
+		Application: toProto(event.Application),
+	}
+}
+
+func toProto(metadata *types.ApplicationMetadata) *upboundv1.Application {
+	app := &upboundv1.Application{
+		Title:       metadata.Title,
+		Version:     metadata.Version,
+		Company:     metadata.Company,
+		Website:     metadata.Website,
+		Source:      metadata.Source,
+		License:     metadata.License,
+		Description: metadata.Description,
+	}
+	for _, m := range metadata.Maintainers {
+		if m != nil {
+			app.Maintainers = append(app.Maintainers, &upboundv1.Maintainer{Name: m.Name, Email: m.Email})
+		}
+	}
+	return app
+}
+
+// fromProto converts a submitted application, nil converts to an empty one which fails validation.
+func fromProto(app *upboundv1.Application) *types.ApplicationMetadata {
+	metadata := &types.ApplicationMetadata{
+		Title:       app.GetTitle(),
+		Version:     app.GetVersion(),
+		Company:     app.GetCompany(),
+		Website:     app.GetWebsite(),
+		Source:      app.GetSource(),
+		License:     app.GetLicense(),
+		Description: app.GetDescription(),
+	}
+	for _, m := range app.GetMaintainers() {
+		metadata.Maintainers = append(metadata.Maintainers, &types.Maintainer{Name: m.GetName(), Email: m.GetEmail()})
+	}
+	return metadata
+}
@@ -1,13 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/alexeldeib/upbound/pkg/admission"
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/config"
+	"github.com/alexeldeib/upbound/pkg/health"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/metrics"
+	"github.com/alexeldeib/upbound/pkg/policy"
+	"github.com/alexeldeib/upbound/pkg/ratelimit"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	"github.com/alexeldeib/upbound/pkg/tracing"
 	"github.com/alexeldeib/upbound/pkg/types"
 	"github.com/alexeldeib/upbound/pkg/util"
-	log "github.com/sirupsen/logrus"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
 	validator "gopkg.in/go-playground/validator.v9"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -15,12 +32,54 @@ import (
 // Server represents the global HTTP server and contains global state.
 type Server struct {
 	Applications []*types.ApplicationMetadata
+	Transfers    []*types.Transfer   // Pending ownership transfers awaiting acceptance.
 	Validate     *validator.Validate // Caches struct info, so single global instance.
+	Webhooks     *webhooks.Dispatcher
+	Admission    *admission.Chain // Organization-specific hooks run on every create and update.
+	Policy       *policy.Policy   // Compliance rules checked after admission hooks, nil when none are configured.
+	Auth         *auth.Authenticator
+	RBAC         *rbac.Authorizer // Role bindings, enforced around every route by Handler.
+	AuditLog     *audit.Log
+	MaxBodyBytes int64              // Largest accepted request body, unlimited when zero.
+	Limiter      *ratelimit.Limiter // Per-client request budgets, unlimited when nil.
+	Health       *health.Registry   // Checks behind the probe endpoints, subsystems configured outside the server register their own.
+	Metrics      *metrics.Metrics
+
+	// Reverse proxies whose X-Forwarded-For names the client, set through TrustProxies. Peers are the clients when empty.
+	TrustedProxies []*net.IPNet
+
+	mu sync.RWMutex // Guards Applications and Transfers against concurrent handlers.
 }
 
 // NewServer prepares a server with handlers, validation, and global application metadata.
 func NewServer() Server {
-	return Server{Applications: make([]*types.ApplicationMetadata, 0), Validate: validator.New()}
+	return Server{Applications: make([]*types.ApplicationMetadata, 0), Validate: validator.New(), Webhooks: webhooks.NewDispatcher(), Admission: &admission.Chain{}, Auth: &auth.Authenticator{Tokens: auth.NewTokenStore()}, RBAC: &rbac.Authorizer{}, AuditLog: &audit.Log{}, MaxBodyBytes: config.DefaultMaxBodyBytes, Health: health.NewRegistry(), Metrics: metrics.New()}
+}
+
+// checkStorage is a health check confirming applications can be read, which fails if a handler is stuck holding the lock.
+func (srv *Server) checkStorage(ctx context.Context) error {
+	locked := make(chan struct{})
+	go func() {
+		srv.mu.RLock()
+		srv.mu.RUnlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return errors.New("in-memory store is locked")
+	}
+}
+
+// Shutdown flushes background work once the HTTP server has stopped handing it requests. Applications and tokens live
+// in memory, so only webhook deliveries need draining before the audit log's file is closed.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	err := srv.Webhooks.Close(ctx)
+	if closeErr := srv.AuditLog.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 // Create handles requests from users to create and persist application metadata.
@@ -29,71 +88,122 @@ func (srv *Server) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Please use a PUT request to create an application.", http.StatusBadRequest)
 		return
 	}
-	// Read in body
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body of request", http.StatusInternalServerError)
+	metadata, ok := srv.decode(w, r)
+	if !ok {
+		return
 	}
-	// Try to parse the metadata content
-	metadata := &types.ApplicationMetadata{}
-	err = yaml.Unmarshal(body, metadata)
-	if err != nil {
-		http.Error(w, "Failed to parse YAML input. This likely indicates malformed request body. Verify the payload fields and parameter types are correct.", http.StatusBadRequest)
-		log.Info("YAML parse error")
+	if err := srv.createApplication(r.Context(), r.RemoteAddr, metadata); err != nil {
+		srv.fail(w, err)
 		return
 	}
+	w.WriteHeader(http.StatusCreated)
+}
 
-	// Validate input
-	err = srv.Validate.Struct(metadata)
-	if err != nil {
-		// If we fail to validate, automatically return 400
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to validate input of the following parameters:\n"))
+// Update replaces the metadata of an existing application, matched by title.
+func (srv *Server) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Please use a PUT request to update an application.", http.StatusBadRequest)
+		return
+	}
+	metadata, ok := srv.decode(w, r)
+	if !ok {
+		return
+	}
+	if err := srv.updateApplication(r.Context(), r.RemoteAddr, metadata); err != nil {
+		srv.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
 
-		// Be helpful and tell users what fails in their request
-		for _, err := range err.(validator.ValidationErrors) {
-			fmt.Fprintf(w, "%s has invalid value %s\n", err.Namespace(), err.Value())
-		}
-		log.Info("Rejected invalid input.")
+// Delete removes the application named by the title query parameter.
+func (srv *Server) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Please use a DELETE request to delete an application.", http.StatusBadRequest)
+		return
+	}
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		http.Error(w, "Please provide the title of the application to delete as a query parameter.", http.StatusBadRequest)
 		return
 	}
 
-	// Check if a conflicting application already exists
-	if util.CheckTitle(srv.Applications, metadata.Title) {
-		w.WriteHeader(http.StatusConflict)
-		fmt.Fprintf(w, "An application with title %s already exists, please use a unique title.", metadata.Title)
+	if err := srv.deleteApplication(r.Context(), r.RemoteAddr, title); err != nil {
+		srv.fail(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Get responds with the application named by the title query parameter.
+func (srv *Server) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to fetch an application.", http.StatusBadRequest)
+		return
+	}
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		http.Error(w, "Please provide the title of the application to fetch as a query parameter.", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	srv.Applications = append(srv.Applications, metadata)
-	log.WithFields(log.Fields{"name": metadata.Title}).Info("Object added")
-	return
+	metadata := srv.lookup(r.Context(), title)
+	if metadata == nil {
+		srv.fail(w, &NotFoundError{Title: title})
+		return
+	}
+	srv.respond(w, metadata)
 }
 
 // Search matches user-provided parmaters partially or exactly against existing applications, returning a list of matches.
+// With explain=true in the query string, the matches are returned alongside a field by field explanation of how every
+// application compared, and with an X-Debug: true header alongside the comparison's debug log.
 func (srv *Server) Search(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Please use a POST request to search for an application.", http.StatusBadRequest)
 		return
 	}
-	// Read in body
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body of request", http.StatusInternalServerError)
+	// Parse it into a struct, but skip validation
+	explaining := false
+	if explain := r.URL.Query().Get("explain"); explain != "" {
+		var err error
+		if explaining, err = strconv.ParseBool(explain); err != nil {
+			http.Error(w, "Please provide explain as true or false.", http.StatusBadRequest)
+			return
+		}
+	}
+	metadata, ok := srv.decode(w, r)
+	if !ok {
 		return
 	}
 
-	// Parse it into a struct, but skip validation
-	metadata := &types.ApplicationMetadata{}
-	err = yaml.Unmarshal(body, metadata)
-	if err != nil {
-		http.Error(w, "Failed to parse YAML input. This likely indicates malformed request body. Verify the payload fields and parameter types are correct.", http.StatusBadRequest)
-		return
+	ctx := r.Context()
+	var recorder *logging.Recorder
+	if debugging(r) {
+		ctx, recorder = logging.Capture(ctx)
+	}
+	compare := util.Compare
+	var explanations []*util.Explanation
+	if explaining {
+		compare = func(ctx context.Context, known *types.ApplicationMetadata, desired *types.ApplicationMetadata) bool {
+			explanation := util.Explain(ctx, known, desired)
+			explanations = append(explanations, explanation)
+			return explanation.Matched
+		}
 	}
+	matches := srv.search(r.Context(), ctx, metadata, compare)
 
-	matches := util.Filter(srv.Applications, metadata, util.Compare)
-	data, err := yaml.Marshal(matches)
+	var body interface{} = matches
+	if explaining || recorder != nil {
+		response := searchResponse{Matches: matches, Explanations: explanations}
+		if recorder != nil {
+			response.Trace = candidateTraces(recorder.Steps())
+		}
+		body = response
+	}
+	_, span := tracing.Start(r.Context(), "encode")
+	data, err := yaml.Marshal(body)
+	span.End()
 	if err != nil {
 		http.Error(w, "Failed to marshal search matches. This is likely a server error.", http.StatusInternalServerError)
 		return
@@ -102,3 +212,96 @@ func (srv *Server) Search(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 	return
 }
+
+// queryKind classifies a search for metrics: all for an empty query, the field's name for single field queries,
+// and compound otherwise.
+func queryKind(query *types.ApplicationMetadata) string {
+	kind := "all"
+	v := reflect.ValueOf(query).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			continue
+		}
+		if kind != "all" {
+			return "compound"
+		}
+		kind = strings.ToLower(v.Type().Field(i).Name)
+	}
+	return kind
+}
+
+// catalog counts applications and their distinct maintainers and companies for the catalog gauges.
+func (srv *Server) catalog() metrics.Catalog {
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	maintainers := make(map[string]bool)
+	companies := make(map[string]bool)
+	for _, app := range srv.Applications {
+		companies[app.Company] = true
+		for _, m := range app.Maintainers {
+			if m != nil {
+				maintainers[strings.ToLower(m.Email)] = true
+			}
+		}
+	}
+	return metrics.Catalog{Applications: len(srv.Applications), Maintainers: len(maintainers), Companies: len(companies)}
+}
+
+// validate checks a request payload against its struct tags, writing an error response and returning false on failure.
+func (srv *Server) validate(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := srv.check(r.Context(), v); err != nil {
+		// Be helpful and tell users what fails in their request
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err)
+		return false
+	}
+	return true
+}
+
+// admit reviews the application, writing an error response and returning false if any step refuses it.
+func (srv *Server) admit(w http.ResponseWriter, r *http.Request, metadata *types.ApplicationMetadata) bool {
+	if err := srv.review(r.Context(), metadata); err != nil {
+		srv.fail(w, err)
+		return false
+	}
+	return true
+}
+
+// fail writes the error response of an operation on applications.
+func (srv *Server) fail(w http.ResponseWriter, err error) {
+	switch err.(type) {
+	case *InvalidError, *PolicyError:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, err)
+	case *ConflictError:
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, err)
+	case *NotFoundError:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, err)
+	case *admission.Failure:
+		// Only blame the server when a hook could not be consulted.
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case *audit.WriteError:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// PolicyTest evaluates a document against every policy rule without persisting it, reporting each rule's result.
+func (srv *Server) PolicyTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Please use a POST request to test an application against policies.", http.StatusBadRequest)
+		return
+	}
+	metadata, ok := srv.decode(w, r)
+	if !ok {
+		return
+	}
+	results := []policy.Result{}
+	if srv.Policy != nil {
+		results = srv.Policy.Evaluate(metadata)
+	}
+	srv.respond(w, results)
+}
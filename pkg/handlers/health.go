@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// HealthReport runs every health check and lists the results, responding 503 unless the server is ready.
+func (srv *Server) HealthReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to report on health.", http.StatusBadRequest)
+		return
+	}
+	report := srv.Health.Run(r.Context())
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	srv.respond(w, report)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// ServeMetrics exposes the server's metrics to Prometheus.
+func (srv *Server) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to scrape metrics.", http.StatusBadRequest)
+		return
+	}
+	srv.Metrics.Handler().ServeHTTP(w, r)
+}
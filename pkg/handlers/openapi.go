@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/health"
+	"github.com/alexeldeib/upbound/pkg/openapi"
+	"github.com/alexeldeib/upbound/pkg/policy"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// OpenAPI serves the OpenAPI document describing every route.
+func (srv *Server) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to fetch the API description.", http.StatusBadRequest)
+		return
+	}
+	data, err := yaml.Marshal(srv.Spec())
+	if err != nil {
+		http.Error(w, "Failed to marshal the API description. This is likely a server error.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", openapi.YAML)
+	w.Write(data)
+}
+
+// Spec generates the OpenAPI document from the routes and the types their handlers read and write.
+func (srv *Server) Spec() *openapi.Document {
+	docs := srv.docs()
+	routes := make([]openapi.Route, 0)
+	for _, route := range srv.Routes() {
+		routes = append(routes, openapi.Route{Path: route.Path, Scope: route.Action, Methods: docs[route.Path]})
+	}
+	text := ""
+	routes = append(routes,
+		openapi.Route{Path: "/healthz", Public: true, Methods: []openapi.Method{{Method: "GET", Summary: "Liveness probe.", Status: http.StatusOK, Response: text}}},
+		openapi.Route{Path: "/readyz", Public: true, Methods: []openapi.Method{{Method: "GET", Summary: "Readiness probe, failing while draining or unhealthy.", Status: http.StatusOK, Response: text}}},
+		openapi.Route{Path: "/openapi.yaml", Public: true, Methods: []openapi.Method{{Method: "GET", Summary: "This document.", Status: http.StatusOK, Response: map[string]interface{}{}}}},
+		openapi.Route{Path: ApplicationSchemaPath, Public: true, Methods: []openapi.Method{{Method: "GET", Summary: "JSON Schema of application manifests.", Status: http.StatusOK, Response: map[string]interface{}{}, Type: SchemaJSON}}},
+	)
+	info := openapi.Info{
+		Title:       "upbound",
+		Description: "Stores application metadata and searches it. Bodies are YAML, requests may also be sent as JSON.",
+		Version:     "v1",
+	}
+	return openapi.NewGenerator().Document(info, routes)
+}
+
+// docs describes the methods of every route, keyed by path.
+func (srv *Server) docs() map[string][]openapi.Method {
+	app := &types.ApplicationMetadata{}
+	text := ""
+	return map[string][]openapi.Method{
+		"/create": {
+			{Method: "PUT", Summary: "Create an application.", Request: app, Status: http.StatusCreated},
+		},
+		"/update": {
+			{Method: "PUT", Summary: "Replace an application's metadata, matched by title.", Request: app, Status: http.StatusOK},
+		},
+		"/delete": {
+			{Method: "DELETE", Summary: "Delete an application.", Parameters: []openapi.Parameter{param("title", "Title of the application.", true)}, Status: http.StatusOK},
+		},
+		"/get": {
+			{Method: "GET", Summary: "Fetch an application.", Parameters: []openapi.Parameter{param("title", "Title of the application.", true)}, Status: http.StatusOK, Response: app},
+		},
+		"/watch": {
+			{
+				Method:     "GET",
+				Summary:    "Stream application events as they happen, each a YAML document ended by \"...\".",
+				Parameters: []openapi.Parameter{param("title", "Only stream events of this application.", false)},
+				Status:     http.StatusOK,
+				Response:   &webhooks.Event{},
+			},
+		},
+		"/transfers": {
+			{Method: "GET", Summary: "List pending transfers the caller is party to.", Status: http.StatusOK, Response: []*types.Transfer{}},
+			{Method: "PUT", Summary: "Propose handing an application over to another maintainer.", Request: &types.Transfer{}, Status: http.StatusAccepted, Response: &types.Transfer{}},
+			{Method: "DELETE", Summary: "Cancel a pending transfer.", Parameters: []openapi.Parameter{param("id", "ID of the transfer.", true)}, Status: http.StatusOK},
+		},
+		"/transfers/accept": {
+			{Method: "PUT", Summary: "Accept a transfer as its new maintainer.", Parameters: []openapi.Parameter{param("id", "ID of the transfer.", true)}, Status: http.StatusOK},
+		},
+		"/search": {
+			{
+				Method:  "POST",
+				Summary: "Search applications, fields left empty in the query match anything.",
+				Parameters: []openapi.Parameter{
+					{Name: "explain", In: "query", Description: "Explain how every application compared, field by field.", Schema: &openapi.Schema{Type: "boolean"}},
+					{Name: DebugHeader, In: "header", Description: "Attach the comparison's debug log.", Schema: &openapi.Schema{Type: "boolean"}},
+				},
+				Request:  openapi.Query{Value: app},
+				Status:   http.StatusOK,
+				Response: openapi.OneOf{[]*types.ApplicationMetadata{}, &searchResponse{}},
+			},
+		},
+		"/policies/test": {
+			{Method: "POST", Summary: "Evaluate every policy rule against an application without storing it.", Request: openapi.Query{Value: app}, Status: http.StatusOK, Response: []policy.Result{}},
+		},
+		"/validate": {
+			{
+				Method:     "POST",
+				Summary:    "Run every check of a create or update, including title uniqueness, without storing the application.",
+				Parameters: []openapi.Parameter{{Name: "operation", In: "query", Description: "The operation to check for.", Schema: &openapi.Schema{Type: "string", Enum: []string{"create", "update"}}}},
+				Request:    app,
+				Status:     http.StatusOK,
+				Response:   app,
+			},
+		},
+		"/metrics": {
+			{Method: "GET", Summary: "Prometheus metrics.", Status: http.StatusOK, Response: text},
+		},
+		"/webhooks": {
+			{Method: "GET", Summary: "List webhook subscriptions.", Status: http.StatusOK, Response: []*webhooks.Subscription{}},
+			{Method: "PUT", Summary: "Subscribe a URL to application events.", Request: &webhooks.Subscription{}, Status: http.StatusCreated, Response: &webhooks.Subscription{}},
+			{Method: "DELETE", Summary: "Remove a webhook subscription.", Parameters: []openapi.Parameter{param("id", "ID of the subscription.", true)}, Status: http.StatusOK},
+		},
+		"/webhooks/deliveries": {
+			{Method: "GET", Summary: "List webhook delivery attempts.", Parameters: []openapi.Parameter{param("id", "Only list deliveries to this subscription.", false)}, Status: http.StatusOK, Response: []*webhooks.Delivery{}},
+		},
+		"/webhooks/deadletters": {
+			{Method: "GET", Summary: "List events which exhausted their delivery retries.", Status: http.StatusOK, Response: []*webhooks.DeadLetter{}},
+		},
+		"/tokens": {
+			{Method: "GET", Summary: "List API tokens.", Status: http.StatusOK, Response: []*auth.Token{}},
+			{Method: "PUT", Summary: "Issue an API token, the secret is only returned now.", Request: &tokenRequest{}, Status: http.StatusCreated, Response: &issuedToken{}},
+			{Method: "DELETE", Summary: "Revoke an API token.", Parameters: []openapi.Parameter{param("id", "ID of the token.", true)}, Status: http.StatusOK},
+		},
+		"/rolebindings": {
+			{Method: "GET", Summary: "List role bindings.", Status: http.StatusOK, Response: []*rbac.Binding{}},
+			{Method: "PUT", Summary: "Bind a role to users or groups.", Request: &rbac.Binding{}, Status: http.StatusCreated, Response: &rbac.Binding{}},
+			{Method: "DELETE", Summary: "Remove a role binding.", Parameters: []openapi.Parameter{param("id", "ID of the role binding.", true)}, Status: http.StatusOK},
+		},
+		"/audit": {
+			{
+				Method:  "GET",
+				Summary: "Query the audit log.",
+				Parameters: []openapi.Parameter{
+					param("title", "Only entries for this application.", false),
+					param("actor", "Only entries by this actor.", false),
+					{Name: "since", In: "query", Description: "Only entries from this time on.", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				},
+				Status:   http.StatusOK,
+				Response: []audit.Entry{},
+			},
+		},
+		"/audit/verify": {
+			{Method: "GET", Summary: "Check the audit log's hash chain is intact.", Status: http.StatusOK, Response: text},
+		},
+		"/debug/health": {
+			{Method: "GET", Summary: "Run every health check, responding 503 unless ready.", Status: http.StatusOK, Response: &health.Report{}},
+		},
+		"/debug/loglevel": {
+			{Method: "GET", Summary: "Report the log level.", Status: http.StatusOK, Response: &logLevel{}},
+			{Method: "PUT", Summary: "Change the log level.", Request: &logLevel{}, Status: http.StatusOK, Response: &logLevel{}},
+		},
+	}
+}
+
+// param describes a string query parameter.
+func param(name string, description string, required bool) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: &openapi.Schema{Type: "string"}}
+}
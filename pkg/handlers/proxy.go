@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForHeader lists the addresses a request passed through, each proxy appending the one it received it from.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// TrustProxies parses the addresses and CIDRs of the reverse proxies whose X-Forwarded-For header names the client.
+func (srv *Server) TrustProxies(addrs ...string) error {
+	proxies := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", addr)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %v", addr, err)
+		}
+		proxies = append(proxies, network)
+	}
+	srv.TrustedProxies = proxies
+	return nil
+}
+
+// trusted reports whether host is the address of a trusted proxy.
+func (srv *Server) trusted(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range srv.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr returns the address of the client behind the peer at remote. Forwarded addresses are only believed from
+// trusted proxies, so they are walked from the nearest hop back to the first one not added by a trusted proxy; the
+// earlier ones could have been sent by the client itself.
+func (srv *Server) clientAddr(remote string, forwarded []string) string {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	if !srv.trusted(host) {
+		return remote
+	}
+	hops := make([]string, 0)
+	for _, value := range forwarded {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			// A garbled hop can't be attributed to anyone, so the last proxy which handled the request answers for it.
+			break
+		}
+		client = hops[i]
+		if !srv.trusted(hops[i]) {
+			break
+		}
+	}
+	return client
+}
+
+// forwarded replaces the remote address of requests relayed by a trusted proxy with the client's, so access logs,
+// audit entries and rate limits all see the caller rather than the proxy.
+func (srv *Server) forwarded(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = srv.clientAddr(r.RemoteAddr, r.Header.Values(ForwardedForHeader))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	log "github.com/sirupsen/logrus"
+)
+
+// RoleBindings lists (GET), creates (PUT) and removes (DELETE with an id query parameter) role bindings.
+func (srv *Server) RoleBindings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		srv.respond(w, srv.RBAC.Bindings())
+	case "PUT":
+		binding := &rbac.Binding{}
+		if !srv.parse(w, r, binding) {
+			return
+		}
+		if !srv.validate(w, r, binding) {
+			return
+		}
+		if err := binding.Validate(); err != nil {
+			http.Error(w, "Invalid role binding: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		srv.RBAC.Bind(binding)
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": binding.ID, "role": binding.Role, "company": binding.Company}).Info("Role bound")
+		srv.auditTarget(r, audit.Entry{Action: "rolebinding.create", Target: binding.ID})
+		w.WriteHeader(http.StatusCreated)
+		srv.respond(w, binding)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if !srv.RBAC.Unbind(id) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "No role binding with id %s exists.", id)
+			return
+		}
+		srv.auditTarget(r, audit.Entry{Action: "rolebinding.delete", Target: id})
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": id}).Info("Role unbound")
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Please use a GET, PUT or DELETE request to manage role bindings.", http.StatusBadRequest)
+	}
+}
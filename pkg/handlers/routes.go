@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/alexeldeib/upbound/pkg/ratelimit"
+	"github.com/alexeldeib/upbound/pkg/rbac"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+)
+
+// Route binds a path to its handler, the kind of action it performs and how to find the applications it writes.
+type Route struct {
+	Path    string
+	Action  string
+	Handler http.HandlerFunc
+	Resolve rbac.Resolver
+}
+
+// Routes lists every endpoint served by the server.
+func (srv *Server) Routes() []Route {
+	return []Route{
+		{"/create", rbac.Write, srv.Create, srv.created},
+		{"/update", rbac.Write, srv.Update, srv.updated},
+		{"/delete", rbac.Write, srv.Delete, srv.deleted},
+		{"/get", rbac.Read, srv.Get, nil},
+		{"/watch", rbac.Read, srv.Watch, nil},
+		{"/transfers", rbac.Write, srv.Transfer, srv.transferred},
+		{"/transfers/accept", rbac.Write, srv.AcceptTransfer, nil},
+		{"/search", rbac.Read, srv.Search, nil},
+		{"/policies/test", rbac.Read, srv.PolicyTest, nil},
+		{"/validate", rbac.Read, srv.DryRun, nil},
+		{"/metrics", rbac.Read, srv.ServeMetrics, nil},
+		{"/webhooks", rbac.Admin, srv.WebhookSubscriptions, nil},
+		{"/webhooks/deliveries", rbac.Admin, srv.WebhookDeliveries, nil},
+		{"/webhooks/deadletters", rbac.Admin, srv.WebhookDeadLetters, nil},
+		{"/tokens", rbac.Admin, srv.Tokens, nil},
+		{"/rolebindings", rbac.Admin, srv.RoleBindings, nil},
+		{"/audit", rbac.Admin, srv.Audit, nil},
+		{"/audit/verify", rbac.Admin, srv.AuditVerify, nil},
+		{"/debug/health", rbac.Admin, srv.HealthReport, nil},
+		{"/debug/loglevel", rbac.Admin, srv.LogLevel, nil},
+	}
+}
+
+// Handler returns a mux serving every route instrumented, traced and access logged, and behind body size limits,
+// authentication, rate limiting and authorization.
+// Probes, the API description and the application schema are served without, since the kubelet sends no credentials
+// and clients need the descriptions before they have any.
+func (srv *Server) Handler() http.Handler {
+	srv.Health.Register("storage", srv.checkStorage)
+	srv.Health.Register("webhooks", func(ctx context.Context) error { return srv.Webhooks.Check(ctx) })
+	srv.Health.Register("audit", func(ctx context.Context) error { return srv.AuditLog.Check(ctx) })
+	srv.Metrics.SetCatalog(srv.catalog)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", srv.Health.Live)
+	mux.HandleFunc("/readyz", srv.Health.Ready)
+	mux.HandleFunc("/openapi.yaml", srv.OpenAPI)
+	mux.HandleFunc(ApplicationSchemaPath, srv.ApplicationSchema)
+	for _, route := range srv.Routes() {
+		var h http.Handler = srv.RBAC.Authorize(route.Action, route.Resolve, route.Handler)
+		if route.Action != rbac.Read {
+			h = srv.auditing(h)
+		}
+		if srv.Limiter != nil {
+			// Admin routes share the write budget, they are rare and just as expensive.
+			class := ratelimit.Write
+			if route.Action == rbac.Read {
+				class = ratelimit.Read
+			}
+			h = srv.Limiter.Middleware(class, h)
+		}
+		h = srv.Auth.Authenticate(route.Action == rbac.Read, h)
+		if srv.Limiter != nil {
+			// Failed authentications are limited by IP before credentials are checked, so they can't be guessed.
+			h = srv.Limiter.Guard(h)
+		}
+		h = srv.limitBody(h)
+		mux.Handle(route.Path, srv.Metrics.Instrument(route.Path, tracing.Middleware(route.Path, accessLog(route.Path, h))))
+	}
+	if len(srv.TrustedProxies) > 0 {
+		return srv.forwarded(mux)
+	}
+	return mux
+}
+
+// created resolves the submitted document of a create.
+func (srv *Server) created(r *http.Request) (*types.ApplicationMetadata, *types.ApplicationMetadata) {
+	proposed := &types.ApplicationMetadata{}
+	if !peek(r, proposed) {
+		return nil, nil
+	}
+	return nil, proposed
+}
+
+// updated resolves the stored application an update replaces, along with the submitted document.
+func (srv *Server) updated(r *http.Request) (*types.ApplicationMetadata, *types.ApplicationMetadata) {
+	proposed := &types.ApplicationMetadata{}
+	if !peek(r, proposed) {
+		return nil, nil
+	}
+	return srv.lookup(r.Context(), proposed.Title), proposed
+}
+
+// deleted resolves the stored application a delete removes.
+func (srv *Server) deleted(r *http.Request) (*types.ApplicationMetadata, *types.ApplicationMetadata) {
+	return srv.lookup(r.Context(), r.URL.Query().Get("title")), nil
+}
+
+// transferred resolves the stored application a transfer proposal hands over.
+func (srv *Server) transferred(r *http.Request) (*types.ApplicationMetadata, *types.ApplicationMetadata) {
+	transfer := &types.Transfer{}
+	if r.Method != "PUT" || !peek(r, transfer) {
+		return nil, nil
+	}
+	return srv.lookup(r.Context(), transfer.Title), nil
+}
+
+// lookup returns the application with the title, or nil if there is none.
+func (srv *Server) lookup(ctx context.Context, title string) *types.ApplicationMetadata {
+	_, span := tracing.Start(ctx, "store.lookup")
+	defer span.End()
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	if i := util.FindTitle(srv.Applications, title); i >= 0 {
+		return srv.Applications[i]
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/admission"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/policy"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// The operations on applications below are shared by the HTTP and gRPC APIs, which map their errors to statuses.
+
+// ConflictError is a create of an application whose title is taken.
+type ConflictError struct {
+	Title string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("An application with title %s already exists, please use a unique title.", e.Title)
+}
+
+// NotFoundError is an operation on an application which doesn't exist.
+type NotFoundError struct {
+	Title string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("No application with title %s exists.", e.Title)
+}
+
+// InvalidError lists the fields of a payload failing struct validation.
+type InvalidError struct {
+	Fields validator.ValidationErrors
+}
+
+func (e *InvalidError) Error() string {
+	var b strings.Builder
+	b.WriteString("Failed to validate input of the following parameters:\n")
+	for _, err := range e.Fields {
+		fmt.Fprintf(&b, "%s has invalid value %s\n", err.Namespace(), err.Value())
+	}
+	return b.String()
+}
+
+// PolicyError lists the policies an application violates.
+type PolicyError struct {
+	Violations []policy.Result
+}
+
+func (e *PolicyError) Error() string {
+	var b strings.Builder
+	b.WriteString("Failed to satisfy the following policies:\n")
+	for _, v := range e.Violations {
+		fmt.Fprintf(&b, "%s: %s\n", v.Rule, v.Message)
+	}
+	return b.String()
+}
+
+// check validates a payload against its struct tags, returning an *InvalidError listing the fields which fail.
+func (srv *Server) check(ctx context.Context, v interface{}) error {
+	_, span := tracing.Start(ctx, "validate")
+	defer span.End()
+	err := srv.Validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	tracing.Fail(span, err)
+	invalid := &InvalidError{Fields: err.(validator.ValidationErrors)}
+	for _, err := range invalid.Fields {
+		srv.Metrics.Reject(err.Namespace(), err.Tag())
+	}
+	logging.FromContext(ctx).Info("Rejected invalid input.")
+	return invalid
+}
+
+// review runs mutating hooks, struct validation and validating hooks in that order, so hooks may fill in required
+// fields, then policy. It returns the admission error, *InvalidError or *PolicyError of the first step refusing the
+// application.
+func (srv *Server) review(ctx context.Context, metadata *types.ApplicationMetadata) error {
+	hookCtx, span := tracing.Start(ctx, "admission.mutate")
+	err := srv.Admission.Mutate(hookCtx, metadata)
+	span.End()
+	if err != nil {
+		return srv.refused(ctx, err)
+	}
+	if err := srv.check(ctx, metadata); err != nil {
+		return err
+	}
+	hookCtx, span = tracing.Start(ctx, "admission.validate")
+	err = srv.Admission.Validate(hookCtx, metadata)
+	span.End()
+	if err != nil {
+		return srv.refused(ctx, err)
+	}
+	if srv.Policy == nil {
+		return nil
+	}
+	_, span = tracing.Start(ctx, "policy")
+	violations := policy.Violations(srv.Policy.Evaluate(metadata))
+	span.SetAttributes(attribute.Int("violations", len(violations)))
+	span.End()
+	if len(violations) > 0 {
+		for _, v := range violations {
+			srv.Metrics.Reject("policy", v.Rule)
+		}
+		logging.FromContext(ctx).WithFields(log.Fields{"name": metadata.Title}).Info("Rejected by policy.")
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// refused records an admission hook's error.
+func (srv *Server) refused(ctx context.Context, err error) error {
+	if rejection, ok := err.(*admission.Rejection); ok {
+		srv.Metrics.Reject("admission", rejection.Hook)
+	}
+	logging.FromContext(ctx).WithFields(log.Fields{"error": err}).Info("Rejected by admission.")
+	return err
+}
+
+// createApplication reviews a new application, records it in the audit log and stores it, then notifies subscribers.
+// Callers are identified by ctx, and the remote address they connect from. Taken titles fail with a *ConflictError,
+// and changes the audit log can't record with a *audit.WriteError.
+func (srv *Server) createApplication(ctx context.Context, remote string, metadata *types.ApplicationMetadata) error {
+	if err := srv.review(ctx, metadata); err != nil {
+		return err
+	}
+	_, span := tracing.Start(ctx, "store.insert")
+	srv.mu.Lock()
+	// Check if a conflicting application already exists
+	if util.CheckTitle(srv.Applications, metadata.Title) {
+		srv.mu.Unlock()
+		span.End()
+		return &ConflictError{Title: metadata.Title}
+	}
+	// Recorded under the lock, so entries are in the order changes were made.
+	if err := srv.auditFrom(ctx, remote, change("create", nil, metadata)); err != nil {
+		srv.mu.Unlock()
+		span.End()
+		return err
+	}
+	srv.Applications = append(srv.Applications, metadata)
+	srv.mu.Unlock()
+	span.End()
+
+	srv.Webhooks.Dispatch(ctx, webhooks.Created, metadata)
+	logging.FromContext(ctx).WithFields(log.Fields{"name": metadata.Title}).Info("Object added")
+	return nil
+}
+
+// updateApplication reviews the application and replaces the stored one with the same title, failing with a
+// *NotFoundError if there is none.
+func (srv *Server) updateApplication(ctx context.Context, remote string, metadata *types.ApplicationMetadata) error {
+	if err := srv.review(ctx, metadata); err != nil {
+		return err
+	}
+	_, span := tracing.Start(ctx, "store.replace")
+	srv.mu.Lock()
+	i := util.FindTitle(srv.Applications, metadata.Title)
+	if i < 0 {
+		srv.mu.Unlock()
+		span.End()
+		return &NotFoundError{Title: metadata.Title}
+	}
+	// Replace rather than mutate, so pending webhook payloads and search results stay consistent.
+	previous := srv.Applications[i]
+	if err := srv.auditFrom(ctx, remote, change("update", previous, metadata)); err != nil {
+		srv.mu.Unlock()
+		span.End()
+		return err
+	}
+	srv.Applications[i] = metadata
+	srv.mu.Unlock()
+	span.End()
+
+	srv.Webhooks.Dispatch(ctx, webhooks.Updated, metadata)
+	logging.FromContext(ctx).WithFields(log.Fields{"name": metadata.Title}).Info("Object updated")
+	return nil
+}
+
+// deleteApplication removes the application with the title, failing with a *NotFoundError if there is none.
+func (srv *Server) deleteApplication(ctx context.Context, remote string, title string) error {
+	_, span := tracing.Start(ctx, "store.delete")
+	srv.mu.Lock()
+	i := util.FindTitle(srv.Applications, title)
+	if i < 0 {
+		srv.mu.Unlock()
+		span.End()
+		return &NotFoundError{Title: title}
+	}
+	metadata := srv.Applications[i]
+	if err := srv.auditFrom(ctx, remote, change("delete", metadata, nil)); err != nil {
+		srv.mu.Unlock()
+		span.End()
+		return err
+	}
+	srv.Applications = append(srv.Applications[:i], srv.Applications[i+1:]...)
+	srv.mu.Unlock()
+	span.End()
+
+	srv.Webhooks.Dispatch(ctx, webhooks.Deleted, metadata)
+	logging.FromContext(ctx).WithFields(log.Fields{"name": title}).Info("Object deleted")
+	return nil
+}
+
+// search returns the applications compare matches with the query, recording the search's span, metrics and a debug
+// line through ctx. Comparisons log through compareCtx instead, so a debugged search can capture them.
+func (srv *Server) search(ctx context.Context, compareCtx context.Context, query *types.ApplicationMetadata, compare func(context.Context, *types.ApplicationMetadata, *types.ApplicationMetadata) bool) []*types.ApplicationMetadata {
+	kind := queryKind(query)
+	_, span := tracing.Start(ctx, "search.filter", attribute.String("query", kind))
+	defer span.End()
+	start := time.Now()
+	srv.mu.RLock()
+	scanned := len(srv.Applications)
+	matches := util.Filter(compareCtx, srv.Applications, query, compare)
+	srv.mu.RUnlock()
+	elapsed := time.Since(start)
+	srv.Metrics.ObserveSearch(kind, elapsed)
+	span.SetAttributes(attribute.Int("applications", scanned), attribute.Int("matches", len(matches)))
+	logging.AddFields(ctx, log.Fields{"results": len(matches)})
+	logging.FromContext(ctx).WithFields(log.Fields{"query": kind, "applications": scanned, "matches": len(matches), "elapsed": elapsed.String()}).Debug("Searched")
+	return matches
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultTokenTTL applies when a token request does not specify its lifetime.
+const defaultTokenTTL = 30 * 24 * time.Hour
+
+// tokenRequest describes a token to issue.
+type tokenRequest struct {
+	Name   string   `yaml:"name" validate:"required"`
+	Email  string   `yaml:"email" validate:"omitempty,email"` // Grants ownership of applications listing this maintainer email.
+	Scopes []string `yaml:"scopes" validate:"required,dive,oneof=read write admin"`
+	TTL    string   `yaml:"ttl"` // A Go duration such as 720h, defaults to 30 days.
+}
+
+// issuedToken is returned exactly once when a token is issued, the secret is not retrievable afterwards.
+type issuedToken struct {
+	Secret     string `yaml:"token"`
+	auth.Token `yaml:",inline"`
+}
+
+// Tokens lists (GET), issues (PUT) and revokes (DELETE with an id query parameter) API tokens.
+func (srv *Server) Tokens(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		srv.respond(w, srv.Auth.Tokens.List())
+	case "PUT":
+		req := &tokenRequest{}
+		if !srv.parse(w, r, req) {
+			return
+		}
+		if !srv.validate(w, r, req) {
+			return
+		}
+		ttl := defaultTokenTTL
+		if req.TTL != "" {
+			var err error
+			ttl, err = time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				http.Error(w, "Please provide the token ttl as a positive duration, e.g. 720h.", http.StatusBadRequest)
+				return
+			}
+		}
+		secret, token := srv.Auth.Tokens.Issue(req.Name, req.Email, req.Scopes, ttl)
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": token.ID, "name": token.Name, "scopes": token.Scopes}).Info("Token issued")
+		srv.auditTarget(r, audit.Entry{Action: "token.issue", Target: token.ID})
+		w.WriteHeader(http.StatusCreated)
+		srv.respond(w, issuedToken{Secret: secret, Token: *token})
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if !srv.Auth.Tokens.Revoke(id) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "No token with id %s exists.", id)
+			return
+		}
+		srv.auditTarget(r, audit.Entry{Action: "token.revoke", Target: id})
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": id}).Info("Token revoked")
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Please use a GET, PUT or DELETE request to manage tokens.", http.StatusBadRequest)
+	}
+}
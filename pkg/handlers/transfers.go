@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transfer lists (GET), proposes (PUT) and cancels or declines (DELETE with an id query parameter) ownership transfers.
+func (srv *Server) Transfer(w http.ResponseWriter, r *http.Request) {
+	id := auth.FromContext(r.Context())
+	switch r.Method {
+	case "GET":
+		srv.mu.RLock()
+		visible := make([]*types.Transfer, 0)
+		for _, t := range srv.Transfers {
+			if involved(id, t) {
+				visible = append(visible, t)
+			}
+		}
+		srv.mu.RUnlock()
+		srv.respond(w, visible)
+	case "PUT":
+		transfer := &types.Transfer{}
+		if !srv.parse(w, r, transfer) {
+			return
+		}
+		if transfer.From == "" && id != nil {
+			transfer.From = id.Email
+		}
+		if !srv.validate(w, r, transfer) {
+			return
+		}
+
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		i := util.FindTitle(srv.Applications, transfer.Title)
+		if i < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "No application with title %s exists.", transfer.Title)
+			return
+		}
+		// Whether the caller may hand the application over at all was decided by the authorizer.
+		app := srv.Applications[i]
+		if maintainer(app, transfer.From) < 0 {
+			http.Error(w, fmt.Sprintf("%s is not a maintainer of %s.", transfer.From, app.Title), http.StatusBadRequest)
+			return
+		}
+		transfer.ID = util.NewID()
+		srv.Transfers = append(srv.Transfers, transfer)
+		logging.FromContext(r.Context()).WithFields(log.Fields{"name": app.Title, "from": transfer.From, "to": transfer.To.Email}).Info("Transfer proposed")
+		w.WriteHeader(http.StatusAccepted)
+		srv.respond(w, transfer)
+	case "DELETE":
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		i := findTransfer(srv.Transfers, r.URL.Query().Get("id"))
+		if i < 0 || !involved(id, srv.Transfers[i]) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "No pending transfer with id %s exists.", r.URL.Query().Get("id"))
+			return
+		}
+		srv.Transfers = append(srv.Transfers[:i], srv.Transfers[i+1:]...)
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Please use a GET, PUT or DELETE request to manage transfers.", http.StatusBadRequest)
+	}
+}
+
+// AcceptTransfer completes the transfer named by the id query parameter, replacing the old maintainer with the new one.
+// Only the receiving maintainer may accept. The changed application is reviewed like any update, and the transfer stays
+// pending if it is refused.
+func (srv *Server) AcceptTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Please use a PUT request to accept a transfer.", http.StatusBadRequest)
+		return
+	}
+	id := auth.FromContext(r.Context())
+	transferID := r.URL.Query().Get("id")
+
+	srv.mu.RLock()
+	i := findTransfer(srv.Transfers, transferID)
+	if i < 0 || !involved(id, srv.Transfers[i]) {
+		srv.mu.RUnlock()
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "No pending transfer with id %s exists.", transferID)
+		return
+	}
+	transfer := srv.Transfers[i]
+	if id == nil || !strings.EqualFold(id.Email, transfer.To.Email) {
+		srv.mu.RUnlock()
+		http.Error(w, "Only the receiving maintainer may accept a transfer.", http.StatusForbidden)
+		return
+	}
+	j := util.FindTitle(srv.Applications, transfer.Title)
+	if j < 0 {
+		srv.mu.RUnlock()
+		srv.fail(w, &NotFoundError{Title: transfer.Title})
+		return
+	}
+	previous := srv.Applications[j]
+	srv.mu.RUnlock()
+
+	// Copy the application so concurrent readers of the old pointer never observe the change.
+	app := *previous
+	// The newcomer takes the old maintainer's place, and is never listed twice.
+	to := *transfer.To
+	app.Maintainers = make([]*types.Maintainer, 0, len(previous.Maintainers)+1)
+	added := false
+	for _, m := range previous.Maintainers {
+		switch {
+		case m != nil && strings.EqualFold(m.Email, to.Email):
+		case m != nil && strings.EqualFold(m.Email, transfer.From):
+			if !added {
+				app.Maintainers = append(app.Maintainers, &to)
+				added = true
+			}
+		default:
+			app.Maintainers = append(app.Maintainers, m)
+		}
+	}
+	if !added {
+		app.Maintainers = append(app.Maintainers, &to)
+	}
+	// Hooks may call out over the network, so review without holding the lock.
+	if !srv.admit(w, r, &app) {
+		return
+	}
+
+	srv.mu.Lock()
+	i = findTransfer(srv.Transfers, transferID)
+	j = util.FindTitle(srv.Applications, transfer.Title)
+	if i < 0 || j < 0 || srv.Applications[j] != previous {
+		srv.mu.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, "The transfer or %s changed while it was being accepted, please retry.", transfer.Title)
+		return
+	}
+	if err := srv.auditFrom(r.Context(), r.RemoteAddr, change("transfer", previous, &app)); err != nil {
+		srv.mu.Unlock()
+		srv.fail(w, err)
+		return
+	}
+	srv.Transfers = append(srv.Transfers[:i], srv.Transfers[i+1:]...)
+	srv.Applications[j] = &app
+	srv.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	srv.Webhooks.Dispatch(r.Context(), webhooks.Updated, &app)
+	logging.FromContext(r.Context()).WithFields(log.Fields{"name": app.Title, "from": transfer.From, "to": transfer.To.Email}).Info("Transfer accepted")
+}
+
+// involved returns true if the identity proposed or receives the transfer, or is an admin.
+func involved(id *auth.Identity, t *types.Transfer) bool {
+	if id == nil {
+		return false
+	}
+	return id.Allows(auth.Admin) || (id.Email != "" && (strings.EqualFold(id.Email, t.From) || strings.EqualFold(id.Email, t.To.Email)))
+}
+
+func findTransfer(ts []*types.Transfer, id string) int {
+	for i, t := range ts {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// maintainer returns the index of the maintainer with the given email, or -1.
+func maintainer(app *types.ApplicationMetadata, email string) int {
+	for i, m := range app.Maintainers {
+		if m != nil && strings.EqualFold(m.Email, email) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/alexeldeib/upbound/pkg/openapi"
+	"github.com/alexeldeib/upbound/pkg/types"
+)
+
+// ApplicationSchemaPath is the stable URL of the JSON Schema of application manifests, for editors and pre-commit
+// hooks to check manifests against.
+const ApplicationSchemaPath = "/schemas/application.json"
+
+// SchemaJSON is the content type of JSON Schema documents.
+const SchemaJSON = "application/schema+json"
+
+// DryRun runs everything a create or update would check, struct tags, admission hooks, policy and title uniqueness,
+// without storing the application. It responds with the application as it would be stored, after mutating hooks.
+// The operation parameter selects the checks of an update instead of a create.
+func (srv *Server) DryRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Please use a POST request to validate an application.", http.StatusBadRequest)
+		return
+	}
+	operation := r.URL.Query().Get("operation")
+	if operation == "" {
+		operation = "create"
+	}
+	if operation != "create" && operation != "update" {
+		http.Error(w, "Please provide operation as create or update.", http.StatusBadRequest)
+		return
+	}
+	metadata, ok := srv.decode(w, r)
+	if !ok {
+		return
+	}
+	if !srv.admit(w, r, metadata) {
+		return
+	}
+
+	exists := srv.lookup(r.Context(), metadata.Title) != nil
+	if operation == "create" && exists {
+		srv.fail(w, &ConflictError{Title: metadata.Title})
+		return
+	}
+	if operation == "update" && !exists {
+		srv.fail(w, &NotFoundError{Title: metadata.Title})
+		return
+	}
+	srv.respond(w, metadata)
+}
+
+// ApplicationSchema serves the JSON Schema of application manifests, identified by the URL it was fetched from.
+func (srv *Server) ApplicationSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to fetch the application schema.", http.StatusBadRequest)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	id := ""
+	if r.Host != "" {
+		id = scheme + "://" + r.Host + ApplicationSchemaPath
+	}
+	schema := openapi.NewJSONSchema(id, "Application metadata", reflect.TypeOf(types.ApplicationMetadata{}))
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to marshal the application schema. This is likely a server error.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", SchemaJSON)
+	w.Write(data)
+}
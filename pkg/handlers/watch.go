@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/openapi"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Watch streams application events as they happen, optionally only those of the application named by the title query
+// parameter. Each event is a YAML document between "---" and "..." markers, so readers know an event is complete
+// without waiting for the next. The stream ends when the client goes away, falls too far behind, or the server shuts
+// down, after which clients should watch again.
+func (srv *Server) Watch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to watch applications.", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Failed to stream events. This is likely a server error.", http.StatusInternalServerError)
+		return
+	}
+	// The server's timeouts bound ordinary requests, not streams.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	events := srv.Webhooks.Watch(r.Context(), r.URL.Query().Get("title"))
+	w.Header().Set("Content-Type", openapi.YAML)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for event := range events {
+		data, err := yaml.Marshal(event)
+		if err != nil {
+			logging.FromContext(r.Context()).WithFields(log.Fields{"event": event.Type, "error": err}).Error("Failed to marshal watched event")
+			return
+		}
+		w.Write([]byte("---\n"))
+		w.Write(data)
+		if _, err := w.Write([]byte("...\n")); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
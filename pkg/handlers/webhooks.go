@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/alexeldeib/upbound/pkg/audit"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/webhooks"
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// WebhookSubscriptions lists (GET), registers (PUT) and removes (DELETE with an id query parameter) webhook subscriptions.
+func (srv *Server) WebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		srv.respond(w, srv.Webhooks.Subscriptions())
+	case "PUT":
+		sub := &webhooks.Subscription{}
+		if !srv.parse(w, r, sub) {
+			return
+		}
+		if !srv.validate(w, r, sub) {
+			return
+		}
+		srv.Webhooks.Subscribe(sub)
+		srv.auditTarget(r, audit.Entry{Action: "webhook.subscribe", Target: sub.ID})
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": sub.ID, "url": sub.URL}).Info("Webhook subscribed")
+		redacted := *sub
+		redacted.Secret = ""
+		w.WriteHeader(http.StatusCreated)
+		srv.respond(w, redacted)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if !srv.Webhooks.Unsubscribe(id) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, "No webhook subscription with id %s exists.", id)
+			return
+		}
+		srv.auditTarget(r, audit.Entry{Action: "webhook.unsubscribe", Target: id})
+		logging.FromContext(r.Context()).WithFields(log.Fields{"id": id}).Info("Webhook unsubscribed")
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "Please use a GET, PUT or DELETE request to manage webhooks.", http.StatusBadRequest)
+	}
+}
+
+// WebhookDeliveries returns the delivery history, optionally filtered by the id query parameter.
+func (srv *Server) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to list webhook deliveries.", http.StatusBadRequest)
+		return
+	}
+	srv.respond(w, srv.Webhooks.History(r.URL.Query().Get("id")))
+}
+
+// WebhookDeadLetters returns events which exhausted their retries.
+func (srv *Server) WebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Please use a GET request to list dead-lettered webhook events.", http.StatusBadRequest)
+		return
+	}
+	srv.respond(w, srv.Webhooks.DeadLetters())
+}
+
+// respond marshals v to YAML as the response body, defaulting to 200 if no status was written.
+func (srv *Server) respond(w http.ResponseWriter, v interface{}) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		http.Error(w, "Failed to marshal response. This is likely a server error.", http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
@@ -0,0 +1,130 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// checkTimeout bounds each check, so one wedged dependency can't hang a probe.
+const checkTimeout = 2 * time.Second
+
+// Check reports a component's health, returning nil when it is healthy.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string        `yaml:"name"`
+	Healthy  bool          `yaml:"healthy"`
+	Error    string        `yaml:"error,omitempty"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Report is the outcome of every check, and whether the server is draining.
+type Report struct {
+	Ready    bool     `yaml:"ready"`
+	Draining bool     `yaml:"draining"`
+	Checks   []Result `yaml:"checks"`
+}
+
+// Registry collects the checks subsystems register, and whether the server is shutting down.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining bool
+}
+
+// NewRegistry returns a registry without checks.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// Register adds a check, replacing any check already registered under the name.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Drain marks the server as shutting down, so readiness fails and load balancers stop sending it traffic.
+func (r *Registry) Drain() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.draining = true
+}
+
+// Run performs every check concurrently, returning results sorted by name.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	report := Report{Draining: r.draining, Checks: make([]Result, 0, len(r.checks))}
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	results := make(chan Result, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			start := time.Now()
+			err := run(ctx, check)
+			result := Result{Name: name, Healthy: err == nil, Duration: time.Since(start)}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results <- result
+		}(name, check)
+	}
+	report.Ready = !report.Draining
+	for range checks {
+		result := <-results
+		report.Ready = report.Ready && result.Healthy
+		report.Checks = append(report.Checks, result)
+	}
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+// run fails the check once its context is done, even if the check itself ignores the context.
+func run(ctx context.Context, check Check) error {
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %v", ctx.Err())
+	}
+}
+
+// Live answers liveness probes. It only shows the process is serving requests, so failing dependencies don't get
+// the pod restarted.
+func (r *Registry) Live(w http.ResponseWriter, req *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// Ready answers readiness probes, failing with 503 and the failed checks while draining or unhealthy.
+func (r *Registry) Ready(w http.ResponseWriter, req *http.Request) {
+	report := r.Run(req.Context())
+	if report.Ready {
+		w.Write([]byte("ok\n"))
+		return
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if report.Draining {
+		w.Write([]byte("draining\n"))
+	}
+	for _, result := range report.Checks {
+		if !result.Healthy {
+			fmt.Fprintf(w, "%s: %s\n", result.Name, result.Error)
+		}
+	}
+	log.WithFields(log.Fields{"draining": report.Draining}).Info("Readiness check failed")
+}
@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Access writes one JSON line per request, separately from the application log so it can be shipped on its own.
+var Access = &log.Logger{
+	Out:       log.StandardLogger().Out,
+	Formatter: &log.JSONFormatter{},
+	Hooks:     make(log.LevelHooks),
+	Level:     log.InfoLevel,
+}
+
+type contextKey struct{}
+
+// request is the logging state of one request. It is shared by pointer, so fields added deep in the handler chain
+// reach the access log written by the middleware.
+type request struct {
+	mu     sync.Mutex
+	entry  *log.Entry
+	fields log.Fields
+}
+
+// NewContext returns a copy of ctx logging through entry.
+func NewContext(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, &request{entry: entry, fields: log.Fields{}})
+}
+
+// FromContext returns the request's log entry, or one on the standard logger outside of requests.
+func FromContext(ctx context.Context) *log.Entry {
+	if req, ok := ctx.Value(contextKey{}).(*request); ok {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		return req.entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// RequestID returns the ID of the request being logged, or "" outside of requests.
+func RequestID(ctx context.Context) string {
+	id, _ := FromContext(ctx).Data["request_id"].(string)
+	return id
+}
+
+// AddFields adds fields to the request's log entry and its access log line. It does nothing outside of requests.
+func AddFields(ctx context.Context, fields log.Fields) {
+	if req, ok := ctx.Value(contextKey{}).(*request); ok {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		req.entry = req.entry.WithFields(fields)
+		for k, v := range fields {
+			req.fields[k] = v
+		}
+	}
+}
+
+// Detach returns a background context logging with the request's entry, for work outliving the request.
+func Detach(ctx context.Context) context.Context {
+	return NewContext(context.Background(), FromContext(ctx))
+}
+
+// Fields returns a copy of the fields added to the request with AddFields.
+func Fields(ctx context.Context) log.Fields {
+	fields := log.Fields{}
+	if req, ok := ctx.Value(contextKey{}).(*request); ok {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		for k, v := range req.fields {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// Step is one line logged through a captured context.
+type Step struct {
+	Message string     `yaml:"message"`
+	Fields  log.Fields `yaml:"fields,omitempty"`
+}
+
+// Recorder is a hook collecting everything logged through a captured context.
+type Recorder struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// Levels captures every level.
+func (rec *Recorder) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire records the entry.
+func (rec *Recorder) Fire(entry *log.Entry) error {
+	fields := make(log.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		fields[k] = v
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.steps = append(rec.steps, Step{Message: entry.Message, Fields: fields})
+	return nil
+}
+
+// Steps returns what was logged, in order.
+func (rec *Recorder) Steps() []Step {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Step(nil), rec.steps...)
+}
+
+// Capture returns a copy of ctx logging at debug level into the returned recorder instead of the log output, whatever
+// the global level. It lets a single request be diagnosed without turning on debug logging for everyone.
+func Capture(ctx context.Context) (context.Context, *Recorder) {
+	rec := &Recorder{}
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.Level = log.DebugLevel
+	logger.AddHook(rec)
+	return NewContext(ctx, logger.WithFields(FromContext(ctx).Data)), rec
+}
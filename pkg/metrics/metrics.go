@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name.
+const Namespace = "upbound"
+
+// indices strips slice indices from validation namespaces, so each field is one series however many maintainers there are.
+var indices = regexp.MustCompile(`\[\d+\]`)
+
+// Catalog is a snapshot of the catalog's size.
+type Catalog struct {
+	Applications int
+	Maintainers  int // Distinct maintainer emails.
+	Companies    int
+}
+
+// Metrics holds the server's Prometheus collectors in their own registry.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	rejections *prometheus.CounterVec
+	searches   *prometheus.HistogramVec
+	calls      *prometheus.CounterVec
+	callTime   *prometheus.HistogramVec
+
+	mu      sync.RWMutex
+	catalog func() Catalog
+}
+
+// New registers the server's metrics, along with the Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to handle HTTP requests, by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "validation_rejections_total",
+			Help:      "Submitted documents rejected, by field and the validation or policy rule they failed.",
+		}, []string{"field", "rule"}),
+		searches: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "search_duration_seconds",
+			Help:      "Time to match a search against the catalog, by the kind of query.",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5},
+		}, []string{"query"}),
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "grpc_requests_total",
+			Help:      "gRPC calls handled, by method and status code.",
+		}, []string{"method", "code"}),
+		callTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "Time to handle gRPC calls, by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+	m.Registry.MustRegister(m.requests, m.latency, m.rejections, m.searches, m.calls, m.callTime, &catalogCollector{m},
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// SetCatalog sets the function the catalog gauges are read from at scrape time.
+func (m *Metrics) SetCatalog(f func() Catalog) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.catalog = f
+}
+
+// Reject counts a document rejected for failing rule on field.
+func (m *Metrics) Reject(field string, rule string) {
+	m.rejections.WithLabelValues(indices.ReplaceAllString(field, ""), rule).Inc()
+}
+
+// ObserveSearch records how long matching a query of the given kind took.
+func (m *Metrics) ObserveSearch(query string, d time.Duration) {
+	m.searches.WithLabelValues(query).Observe(d.Seconds())
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{})
+}
+
+// Instrument counts and times requests to a route. The route is passed in rather than taken from the URL, and
+// methods are folded onto those the API uses, so arbitrary paths and methods can't create new series.
+func (m *Metrics) Instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := util.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+		method := methodLabel(r.Method)
+		m.requests.WithLabelValues(route, method, strconv.Itoa(recorder.Status)).Inc()
+		m.latency.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveCall counts and times a gRPC call to the full method, which ended with the status code, e.g. NotFound.
+// Callers fold methods the server doesn't implement onto one, as Instrument does for HTTP methods.
+func (m *Metrics) ObserveCall(method string, code string, d time.Duration) {
+	m.calls.WithLabelValues(method, code).Inc()
+	m.callTime.WithLabelValues(method).Observe(d.Seconds())
+}
+
+// methodLabel returns the method as a label value, or "other" for methods the API doesn't use.
+func methodLabel(method string) string {
+	switch method {
+	case "GET", "PUT", "POST", "DELETE":
+		return method
+	default:
+		return "other"
+	}
+}
+
+// catalogCollector reads the catalog gauges when scraped, so they never go stale.
+type catalogCollector struct {
+	m *Metrics
+}
+
+var (
+	applicationsDesc = prometheus.NewDesc(Namespace+"_catalog_applications", "Applications in the catalog.", nil, nil)
+	maintainersDesc  = prometheus.NewDesc(Namespace+"_catalog_maintainers", "Distinct maintainers of applications in the catalog.", nil, nil)
+	companiesDesc    = prometheus.NewDesc(Namespace+"_catalog_companies", "Distinct companies with applications in the catalog.", nil, nil)
+)
+
+func (c *catalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- applicationsDesc
+	ch <- maintainersDesc
+	ch <- companiesDesc
+}
+
+func (c *catalogCollector) Collect(ch chan<- prometheus.Metric) {
+	c.m.mu.RLock()
+	f := c.m.catalog
+	c.m.mu.RUnlock()
+	if f == nil {
+		return
+	}
+	catalog := f()
+	ch <- prometheus.MustNewConstMetric(applicationsDesc, prometheus.GaugeValue, float64(catalog.Applications))
+	ch <- prometheus.MustNewConstMetric(maintainersDesc, prometheus.GaugeValue, float64(catalog.Maintainers))
+	ch <- prometheus.MustNewConstMetric(companiesDesc, prometheus.GaugeValue, float64(catalog.Companies))
+}
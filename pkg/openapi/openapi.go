@@ -0,0 +1,407 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Version is the OpenAPI version documents are generated for.
+const Version = "3.0.3"
+
+// Content types of request and response bodies. Bodies are YAML, requests may also be sent as JSON.
+const (
+	YAML = "application/yaml"
+	JSON = "application/json"
+	Text = "text/plain"
+)
+
+// Document is an OpenAPI document, covering the parts the server uses.
+type Document struct {
+	OpenAPI    string                `yaml:"openapi"`
+	Info       Info                  `yaml:"info"`
+	Security   []map[string][]string `yaml:"security"`
+	Paths      map[string]PathItem   `yaml:"paths"`
+	Components Components            `yaml:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Version     string `yaml:"version"`
+}
+
+// PathItem holds the operations of a path, keyed by lowercase method.
+type PathItem map[string]*Operation
+
+// Operation describes one method of a path.
+type Operation struct {
+	Summary     string                `yaml:"summary"`
+	Scope       string                `yaml:"x-scope,omitempty"` // Token scope the caller needs.
+	Security    []map[string][]string `yaml:"security,omitempty"`
+	Parameters  []Parameter           `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty"`
+	Responses   map[string]Response   `yaml:"responses"`
+}
+
+// Parameter is a query parameter.
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description,omitempty"`
+	Required    bool    `yaml:"required,omitempty"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// RequestBody describes what an operation accepts.
+type RequestBody struct {
+	Required bool                 `yaml:"required"`
+	Content  map[string]MediaType `yaml:"content"`
+}
+
+// Response describes one response of an operation.
+type Response struct {
+	Description string               `yaml:"description"`
+	Content     map[string]MediaType `yaml:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body in one content type.
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Components holds the schemas operations refer to.
+type Components struct {
+	Schemas         map[string]*Schema        `yaml:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `yaml:"securitySchemes"`
+}
+
+// SecurityScheme describes how callers authenticate.
+type SecurityScheme struct {
+	Type        string `yaml:"type"`
+	Scheme      string `yaml:"scheme"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Schema is an OpenAPI schema object, which is also valid JSON Schema.
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty" json:"$ref,omitempty"`
+	OneOf                []*Schema          `yaml:"oneOf,omitempty" json:"oneOf,omitempty"`
+	Type                 string             `yaml:"type,omitempty" json:"type,omitempty"`
+	Format               string             `yaml:"format,omitempty" json:"format,omitempty"`
+	Description          string             `yaml:"description,omitempty" json:"description,omitempty"`
+	Enum                 []string           `yaml:"enum,omitempty" json:"enum,omitempty"`
+	MinLength            *int               `yaml:"minLength,omitempty" json:"minLength,omitempty"`
+	MaxLength            *int               `yaml:"maxLength,omitempty" json:"maxLength,omitempty"`
+	Minimum              *float64           `yaml:"minimum,omitempty" json:"minimum,omitempty"`
+	Maximum              *float64           `yaml:"maximum,omitempty" json:"maximum,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty" json:"items,omitempty"`
+	MinItems             *int               `yaml:"minItems,omitempty" json:"minItems,omitempty"`
+	MaxItems             *int               `yaml:"maxItems,omitempty" json:"maxItems,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty" json:"properties,omitempty"`
+	Required             []string           `yaml:"required,omitempty" json:"required,omitempty"`
+	AdditionalProperties interface{}        `yaml:"additionalProperties,omitempty" json:"additionalProperties,omitempty"` // false, or the schema of a map's values.
+}
+
+// Route describes a path for the generator.
+type Route struct {
+	Path    string
+	Scope   string // Token scope the caller needs.
+	Public  bool   // Served without authentication.
+	Methods []Method
+}
+
+// Method describes one method of a route for the generator. Bodies are given as values of their Go types, whose
+// schemas are generated by reflection.
+type Method struct {
+	Method     string
+	Summary    string
+	Parameters []Parameter
+	Request    interface{} // nil without a body.
+	Status     int         // Status of a successful response.
+	Response   interface{} // nil without a body, a string for a plain text body, or OneOf the bodies it may be.
+	Type       string      // Content type of a successful response's body, YAML unless set.
+}
+
+// OneOf lists values of the Go types a body may be, when it depends on the request.
+type OneOf []interface{}
+
+// Query is a request body of a type's shape which the handler doesn't validate, such as a search query. Its schema
+// is named after the type with a Query suffix, and drops the validation keywords.
+type Query struct {
+	Value interface{}
+}
+
+// named keys the schemas of named types, which differ when used as queries.
+type named struct {
+	t     reflect.Type
+	query bool
+}
+
+// Generator builds a document, collecting the schemas of named types into its components.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[named]string
+	prefix  string // Where references to named schemas point.
+}
+
+// NewGenerator returns a generator without schemas.
+func NewGenerator() *Generator {
+	return &Generator{schemas: make(map[string]*Schema), names: make(map[named]string), prefix: "#/components/schemas/"}
+}
+
+// Document generates the document of the routes.
+func (g *Generator) Document(info Info, routes []Route) *Document {
+	doc := &Document{
+		OpenAPI:  Version,
+		Info:     info,
+		Security: []map[string][]string{{"bearer": {}}},
+		Paths:    make(map[string]PathItem),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", Description: "An API token, or a JWT from the configured issuer."},
+			},
+		},
+	}
+	for _, route := range routes {
+		item := make(PathItem)
+		for _, m := range route.Methods {
+			op := &Operation{Summary: m.Summary, Scope: route.Scope, Parameters: m.Parameters, Responses: make(map[string]Response)}
+			if route.Public {
+				// An empty requirement overrides the document's, making the operation public.
+				op.Security = []map[string][]string{{}}
+			}
+			if m.Request != nil {
+				var schema *Schema
+				if query, ok := m.Request.(Query); ok {
+					schema = g.schema(reflect.TypeOf(query.Value), true)
+				} else {
+					schema = g.Schema(reflect.TypeOf(m.Request))
+				}
+				op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{YAML: {schema}, JSON: {schema}}}
+			}
+			success := Response{Description: http.StatusText(m.Status) + "."}
+			contentType := YAML
+			if m.Type != "" {
+				contentType = m.Type
+			}
+			switch body := m.Response.(type) {
+			case nil:
+			case string:
+				success.Content = map[string]MediaType{Text: {&Schema{Type: "string"}}}
+			case OneOf:
+				schema := &Schema{}
+				for _, v := range body {
+					schema.OneOf = append(schema.OneOf, g.Schema(reflect.TypeOf(v)))
+				}
+				success.Content = map[string]MediaType{contentType: {schema}}
+			default:
+				success.Content = map[string]MediaType{contentType: {g.Schema(reflect.TypeOf(body))}}
+			}
+			op.Responses[strconv.Itoa(m.Status)] = success
+			op.Responses["default"] = Response{
+				Description: "The request failed, the body explains why.",
+				Content:     map[string]MediaType{Text: {&Schema{Type: "string"}}},
+			}
+			item[strings.ToLower(m.Method)] = op
+		}
+		doc.Paths[route.Path] = item
+	}
+	return doc
+}
+
+// Schema returns the schema of a Go type as YAML encodes it. Named struct types are added to the components and
+// referred to, so each appears once.
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	return g.schema(t, false)
+}
+
+func (g *Generator) schema(t reflect.Type, query bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == reflect.TypeOf(time.Duration(0)):
+		return &Schema{Type: "string", Format: "duration", Description: "A Go duration such as 1m30s."}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), query)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), query)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, query)
+		}
+		key := named{t, query}
+		name, ok := g.names[key]
+		if !ok {
+			name = schemaName(t)
+			if query {
+				name += "Query"
+			}
+			g.names[key] = name
+			// Register before filling in, so recursive types refer to themselves.
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t, query)
+		}
+		return &Schema{Ref: g.prefix + name}
+	}
+	// Interfaces may hold anything.
+	return &Schema{}
+}
+
+// object builds the schema of a struct's fields, translating their validation tags into schema keywords.
+func (g *Generator) object(t reflect.Type, query bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: false}
+	g.fields(t, query, schema)
+	sort.Strings(schema.Required)
+	return schema
+}
+
+func (g *Generator) fields(t reflect.Type, query bool, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		if f.PkgPath != "" || tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "inline" {
+			inline := f.Type
+			for inline.Kind() == reflect.Ptr {
+				inline = inline.Elem()
+			}
+			g.fields(inline, query, schema)
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			// YAML's default key is the lowercased field name.
+			name = strings.ToLower(f.Name)
+		}
+		property := g.schema(f.Type, query)
+		if !query && constrain(property, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain translates validator tags into keywords on the schema, returning whether the field is required. Tags
+// after dive apply to the items of an array.
+func constrain(schema *Schema, tag string) bool {
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "" {
+			continue
+		}
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+		if name == "dive" {
+			if schema.Items != nil && schema.Items.Ref == "" {
+				constrain(schema.Items, strings.Join(rules[i+1:], ","))
+			}
+			break
+		}
+		switch name {
+		case "required":
+			required = true
+			if schema.Type == "string" {
+				schema.MinLength = intPtr(1)
+			}
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			bound(schema, name, n)
+		}
+	}
+	return required
+}
+
+// bound applies a min or max rule, which bounds length for strings, items for arrays and values for numbers.
+func bound(schema *Schema, rule string, n float64) {
+	switch schema.Type {
+	case "string":
+		if rule == "min" {
+			schema.MinLength = intPtr(int(n))
+		} else {
+			schema.MaxLength = intPtr(int(n))
+		}
+	case "array":
+		if rule == "min" {
+			schema.MinItems = intPtr(int(n))
+		} else {
+			schema.MaxItems = intPtr(int(n))
+		}
+	case "integer", "number":
+		if rule == "min" {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
+
+// schemaName names a type's schema: the bare type name for the API's own types, package qualified otherwise,
+// since packages reuse names like Result.
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	if pkg == "types" || pkg == "handlers" {
+		return string(name)
+	}
+	return pkg + "." + string(name)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// JSONSchemaDraft is the dialect standalone JSON Schemas declare, the one editors most widely support.
+const JSONSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is a standalone JSON Schema document, with the named types it refers to under definitions.
+type JSONSchema struct {
+	Dialect     string             `json:"$schema"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Ref         string             `json:"$ref"`
+	Definitions map[string]*Schema `json:"definitions"`
+}
+
+// NewJSONSchema generates the JSON Schema of a named struct type, identified by id.
+func NewJSONSchema(id string, title string, t reflect.Type) *JSONSchema {
+	g := NewGenerator()
+	g.prefix = "#/definitions/"
+	root := g.Schema(t)
+	return &JSONSchema{Dialect: JSONSchemaDraft, ID: id, Title: title, Ref: root.Ref, Definitions: g.schemas}
+}
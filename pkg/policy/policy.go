@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/google/cel-go/cel"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Rule is a single CEL expression which must evaluate to true for an application to be admitted.
+// Expressions see each ApplicationMetadata field as a variable of the same name, with Maintainers
+// as a list of maps keyed by Name and Email, e.g.
+//
+//	License in ['Apache-2.0', 'MIT'] || Company == 'Random Inc.'
+//	Maintainers.filter(m, m.Email.endsWith('@random.com')).size() >= 2
+type Rule struct {
+	Name       string `yaml:"name"`
+	Expression string `yaml:"expression"`
+	Message    string `yaml:"message"`
+}
+
+// Result is the outcome of evaluating one rule against an application.
+type Result struct {
+	Rule    string `yaml:"rule"`
+	Passed  bool   `yaml:"passed"`
+	Message string `yaml:"message,omitempty"`
+}
+
+// Policy is a compiled set of rules.
+type Policy struct {
+	rules    []Rule
+	programs []cel.Program
+}
+
+// Load reads a YAML policy file with a top level list of rules and compiles it.
+func Load(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := struct {
+		Rules []Rule `yaml:"rules"`
+	}{}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", path, err)
+	}
+	return New(file.Rules)
+}
+
+// New compiles rules, failing on the first rule which does not parse, type check or produce a bool.
+func New(rules []Rule) (*Policy, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("Title", cel.StringType),
+		cel.Variable("Version", cel.StringType),
+		cel.Variable("Maintainers", cel.ListType(cel.MapType(cel.StringType, cel.StringType))),
+		cel.Variable("Company", cel.StringType),
+		cel.Variable("Website", cel.StringType),
+		cel.Variable("Source", cel.StringType),
+		cel.Variable("License", cel.StringType),
+		cel.Variable("Description", cel.StringType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	for _, rule := range rules {
+		if rule.Name == "" || rule.Expression == "" {
+			return nil, fmt.Errorf("policy rules require both a name and an expression")
+		}
+		ast, issues := env.Compile(rule.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("rule %s failed to compile: %v", rule.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("rule %s must evaluate to a bool, not %v", rule.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s failed to compile: %v", rule.Name, err)
+		}
+		policy.rules = append(policy.rules, rule)
+		policy.programs = append(policy.programs, program)
+	}
+	return policy, nil
+}
+
+// Evaluate runs every rule against the application. Rules which error during evaluation count as failed.
+func (p *Policy) Evaluate(app *types.ApplicationMetadata) []Result {
+	activation := variables(app)
+	results := make([]Result, 0, len(p.rules))
+	for i, rule := range p.rules {
+		result := Result{Rule: rule.Name}
+		out, _, err := p.programs[i].Eval(activation)
+		switch {
+		case err != nil:
+			result.Message = fmt.Sprintf("evaluation failed: %v", err)
+		case out.Value() == true:
+			result.Passed = true
+		default:
+			result.Message = rule.Message
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Violations filters results down to the failed rules.
+func Violations(results []Result) []Result {
+	violations := make([]Result, 0)
+	for _, result := range results {
+		if !result.Passed {
+			violations = append(violations, result)
+		}
+	}
+	return violations
+}
+
+func variables(app *types.ApplicationMetadata) map[string]interface{} {
+	maintainers := make([]map[string]string, 0, len(app.Maintainers))
+	for _, m := range app.Maintainers {
+		if m != nil {
+			maintainers = append(maintainers, map[string]string{"Name": m.Name, "Email": m.Email})
+		}
+	}
+	return map[string]interface{}{
+		"Title":       app.Title,
+		"Version":     app.Version,
+		"Maintainers": maintainers,
+		"Company":     app.Company,
+		"Website":     app.Website,
+		"Source":      app.Source,
+		"License":     app.License,
+		"Description": app.Description,
+	}
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Classes of routes with separate budgets, so heavy searching can't starve writes and vice versa. Failed is the
+// budget of failed authentications per client IP, so credentials can't be guessed at the rate of reads.
+const (
+	Read   = "read"
+	Write  = "write"
+	Failed = "failed"
+)
+
+// maxBuckets bounds memory use, idle buckets are pruned once there are more clients than this.
+const maxBuckets = 10000
+
+// Limit is a token bucket refilled at Rate tokens per second up to Burst tokens. A zero rate disables limiting.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type bucket struct {
+	class  string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps one bucket per client and class.
+type Limiter struct {
+	Limits map[string]Limit
+	Now    func() time.Time // Overridable for tests.
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New returns a limiter with the given read, write and failed authentication budgets.
+func New(read Limit, write Limit, failed Limit) *Limiter {
+	return &Limiter{Limits: map[string]Limit{Read: read, Write: write, Failed: failed}, Now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// Decision is the outcome of taking a token.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // Size of the bucket.
+	Remaining  int           // Whole tokens left.
+	RetryAfter time.Duration // Time until the next token, when not allowed.
+	Reset      time.Duration // Time until the bucket is full again.
+}
+
+// Allow takes a token from the client's bucket for the class.
+func (l *Limiter) Allow(client string, class string) Decision {
+	return l.take(client, class, true)
+}
+
+// Check reports whether the client's bucket for the class has a token left, without taking it.
+func (l *Limiter) Check(client string, class string) Decision {
+	return l.take(client, class, false)
+}
+
+func (l *Limiter) take(client string, class string, taking bool) Decision {
+	limit := l.Limits[class]
+	if limit.Rate <= 0 {
+		return Decision{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}
+	}
+	now := l.Now()
+	key := class + "/" + client
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok && !taking {
+		return Decision{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}
+	}
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &bucket{class: class, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	d := Decision{Allowed: b.tokens >= 1, Limit: limit.Burst}
+	if d.Allowed && taking {
+		b.tokens--
+	} else {
+		d.RetryAfter = duration((1 - b.tokens) / limit.Rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = duration((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return d
+}
+
+func duration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// prune drops buckets which have refilled completely, they behave exactly like new ones. Callers must hold the lock.
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		limit := l.Limits[b.class]
+		if limit.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware limits requests per client, keyed by authenticated subject or else by client IP, so it must run after
+// authentication. Limited requests get 429 with Retry-After, and every response carries X-RateLimit-* headers.
+func (l *Limiter) Middleware(class string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ClientIP(r.RemoteAddr)
+		if id := auth.FromContext(r.Context()); id != nil {
+			client = "id:" + id.Principal()
+		}
+
+		d := l.Allow(client, class)
+		if l.Limits[class].Rate > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+		}
+		if !d.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(d.RetryAfter)))
+			http.Error(w, "Too many requests, please retry later.", http.StatusTooManyRequests)
+			logging.FromContext(r.Context()).WithFields(log.Fields{"client": client, "class": class}).Info("Rate limited request")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Guard limits failed authentications per client IP, so it must run before authentication. Clients which spent their
+// budget get 429 without their credentials being checked, and every 401 response takes a token.
+func (l *Limiter) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ClientIP(r.RemoteAddr)
+		if d := l.Check(client, Failed); !d.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds(d.RetryAfter)))
+			http.Error(w, "Too many failed authentications, please retry later.", http.StatusTooManyRequests)
+			logging.FromContext(r.Context()).WithFields(log.Fields{"client": client, "class": Failed}).Info("Rate limited request")
+			return
+		}
+		recorder := util.NewStatusRecorder(w)
+		next.ServeHTTP(recorder, r)
+		if recorder.Status == http.StatusUnauthorized {
+			l.Allow(client, Failed)
+		}
+	})
+}
+
+// ClientIP keys a client by the host of its remote address.
+func ClientIP(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + remote
+}
+
+// seconds rounds up, so clients honoring Retry-After never retry too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
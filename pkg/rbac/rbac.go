@@ -0,0 +1,257 @@
+package rbac
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/alexeldeib/upbound/pkg/auth"
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Roles which can be bound to users and groups.
+const (
+	Viewer       = "viewer"        // Search and read applications.
+	Publisher    = "publisher"     // Create applications, and modify those they maintain.
+	CompanyAdmin = "company-admin" // Modify any application of their company.
+	GlobalAdmin  = "global-admin"  // Modify anything, and manage the server.
+)
+
+// Actions routes are classified by.
+const (
+	Read  = "read"
+	Write = "write"
+	Admin = "admin"
+)
+
+// implied maps token scopes to the role every holder of the scope has, so scoped tokens work without bindings.
+var implied = map[string]string{auth.Read: Viewer, auth.Write: Publisher, auth.Admin: GlobalAdmin}
+
+// Binding grants a role to users and groups. Company scopes company-admin bindings. Users are emails, which match
+// callers of any source just as ownership does, or subjects qualified by their source like token:ci, cert:build-agent
+// or jwt:1234. Groups are qualified the same way, e.g. jwt:admins or cert:platform.
+type Binding struct {
+	ID      string   `yaml:"id"`
+	Role    string   `yaml:"role" validate:"required,oneof=viewer publisher company-admin global-admin"`
+	Company string   `yaml:"company,omitempty"`
+	Users   []string `yaml:"users,omitempty"`
+	Groups  []string `yaml:"groups,omitempty"`
+}
+
+// Validate checks the constraints between fields struct tags can't express.
+func (b *Binding) Validate() error {
+	if len(b.Users) == 0 && len(b.Groups) == 0 {
+		return fmt.Errorf("a binding needs at least one user or group")
+	}
+	for _, user := range b.Users {
+		if !qualified(user) && !strings.Contains(user, "@") {
+			return fmt.Errorf("user %q must be an email, or a subject qualified by its source such as token:ci", user)
+		}
+	}
+	for _, group := range b.Groups {
+		if !qualified(group) {
+			return fmt.Errorf("group %q must be qualified by its source, such as jwt:admins", group)
+		}
+	}
+	if (b.Role == CompanyAdmin) != (b.Company != "") {
+		return fmt.Errorf("company must be set for, and only for, the company-admin role")
+	}
+	return nil
+}
+
+func (b *Binding) matches(id *auth.Identity) bool {
+	for _, user := range b.Users {
+		if qualified(user) {
+			if id.Subject != "" && user == id.Principal() {
+				return true
+			}
+		} else if id.Email != "" && strings.EqualFold(user, id.Email) {
+			return true
+		}
+	}
+	for _, group := range b.Groups {
+		for _, g := range id.Groups {
+			if g != "" && group == id.Source+":"+g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// qualified returns true for a name prefixed by the source it is unique within.
+func qualified(name string) bool {
+	source, rest, ok := strings.Cut(name, ":")
+	return ok && rest != "" && (source == auth.SourceToken || source == auth.SourceCertificate || source == auth.SourceJWT)
+}
+
+// Grant is a role held by an identity, with the company it applies to for company-admins.
+type Grant struct {
+	Role    string
+	Company string
+}
+
+// Authorizer stores role bindings and decides whether identities may act on applications.
+type Authorizer struct {
+	mu       sync.RWMutex
+	bindings []*Binding
+}
+
+// Bind stores a binding, assigning it a fresh ID.
+func (a *Authorizer) Bind(b *Binding) *Binding {
+	b.ID = util.NewID()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.bindings = append(a.bindings, b)
+	return b
+}
+
+// Unbind removes a binding, returning false if it did not exist.
+func (a *Authorizer) Unbind(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, b := range a.bindings {
+		if b.ID == id {
+			a.bindings = append(a.bindings[:i], a.bindings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Bindings lists every stored binding.
+func (a *Authorizer) Bindings() []*Binding {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return append([]*Binding{}, a.bindings...)
+}
+
+// Grants returns the roles an identity holds through its scopes and bindings.
+func (a *Authorizer) Grants(id *auth.Identity) []Grant {
+	grants := make([]Grant, 0)
+	if id == nil {
+		return grants
+	}
+	for _, scope := range id.Scopes {
+		if role, ok := implied[scope]; ok {
+			grants = append(grants, Grant{Role: role})
+		}
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, b := range a.bindings {
+		if b.matches(id) {
+			grants = append(grants, Grant{Role: b.Role, Company: b.Company})
+		}
+	}
+	return grants
+}
+
+// Decide returns "" if the identity may perform the action, or the reason it may not.
+// For writes, existing is the stored application being modified or deleted (nil on create) and proposed
+// the submitted document (nil on delete); either may be nil for writes which don't target one application.
+func (a *Authorizer) Decide(id *auth.Identity, action string, existing *types.ApplicationMetadata, proposed *types.ApplicationMetadata) string {
+	grants := a.Grants(id)
+	has := func(role string) bool {
+		for _, g := range grants {
+			if g.Role == role {
+				return true
+			}
+		}
+		return false
+	}
+	administers := func(company string) bool {
+		for _, g := range grants {
+			if g.Role == CompanyAdmin && company != "" && g.Company == company {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has(GlobalAdmin):
+		return ""
+	case action == Admin:
+		return "Only global admins may manage the server."
+	case action == Read:
+		// Every role includes viewing.
+		if len(grants) > 0 {
+			return ""
+		}
+		return "Reading requires the viewer role."
+	}
+
+	// Company admins manage their company's applications, but can't move them to another company.
+	if (existing == nil || administers(existing.Company)) && (proposed == nil || administers(proposed.Company)) && (existing != nil || proposed != nil) {
+		return ""
+	}
+	if !has(Publisher) {
+		return "Publishing requires the publisher role."
+	}
+	if existing == nil {
+		return ""
+	}
+	if !id.Owns(existing) {
+		return fmt.Sprintf("Only maintainers of %s or admins may modify it.", existing.Title)
+	}
+	// Maintainers can drop co-maintainers, but adding one requires a transfer the newcomer accepts.
+	if proposed != nil {
+		for _, m := range proposed.Maintainers {
+			if m != nil && !maintains(existing, m.Email) {
+				return fmt.Sprintf("Adding maintainer %s requires a transfer accepted by them.", m.Email)
+			}
+		}
+	}
+	return ""
+}
+
+// Scope returns the token scope an action requires.
+func Scope(action string) string {
+	return map[string]string{Read: auth.Read, Write: auth.Write, Admin: auth.Admin}[action]
+}
+
+// Resolver finds the stored and submitted applications a write request targets.
+type Resolver func(r *http.Request) (existing *types.ApplicationMetadata, proposed *types.ApplicationMetadata)
+
+// Authorize wraps a handler so it only runs when the caller's scopes allow the action and their roles allow it on the
+// resources the resolver finds.
+func (a *Authorizer) Authorize(action string, resolve Resolver, next http.Handler) http.Handler {
+	scope := Scope(action)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := auth.FromContext(r.Context())
+		if id == nil {
+			// Only anonymous reads make it past authentication.
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !id.Allows(scope) {
+			http.Error(w, "This identity lacks the "+scope+" scope required for this request.", http.StatusForbidden)
+			logging.FromContext(r.Context()).WithFields(log.Fields{"subject": id.Subject, "scope": scope, "path": r.URL.Path}).Info("Forbidden request")
+			return
+		}
+		var existing, proposed *types.ApplicationMetadata
+		if action == Write && resolve != nil {
+			existing, proposed = resolve(r)
+		}
+		if reason := a.Decide(id, action, existing, proposed); reason != "" {
+			http.Error(w, reason, http.StatusForbidden)
+			logging.FromContext(r.Context()).WithFields(log.Fields{"subject": id.Subject, "path": r.URL.Path, "reason": reason}).Info("Forbidden request")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func maintains(app *types.ApplicationMetadata, email string) bool {
+	for _, m := range app.Maintainers {
+		if m != nil && strings.EqualFold(m.Email, email) {
+			return true
+		}
+	}
+	return false
+}
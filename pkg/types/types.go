@@ -2,18 +2,26 @@ package types
 
 // Maintainer a single maintainer's personal information.
 type Maintainer struct {
-	Name  string `validate:"required"`
-	Email string `validate:"required,email"`
+	Name  string `validate:"required,max=256"`
+	Email string `validate:"required,max=254,email"`
 }
 
 // ApplicationMetadata describes the required information to provision an application.
 type ApplicationMetadata struct {
-	Title       string        `validate:"required"`
-	Version     string        `validate:"required"`
-	Maintainers []*Maintainer `validate:"required,dive,required"`
-	Company     string        `validate:"required"`
-	Website     string        `validate:"required"`
-	Source      string        `validate:"required"`
-	License     string        `validate:"required"`
-	Description string        `validate:"required"`
+	Title       string        `validate:"required,max=256"`
+	Version     string        `validate:"required,max=64"`
+	Maintainers []*Maintainer `validate:"required,max=50,dive,required"`
+	Company     string        `validate:"required,max=256"`
+	Website     string        `validate:"required,max=2048"`
+	Source      string        `validate:"required,max=2048"`
+	License     string        `validate:"required,max=256"`
+	Description string        `validate:"required,max=16384"`
+}
+
+// Transfer is a pending handover of an application from one maintainer to another, which the new maintainer must accept.
+type Transfer struct {
+	ID    string      `yaml:"id"`
+	Title string      `yaml:"title" validate:"required"`
+	From  string      `yaml:"from" validate:"omitempty,email"` // Email of the maintainer being replaced, defaults to the caller.
+	To    *Maintainer `yaml:"to" validate:"required"`
 }
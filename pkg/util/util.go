@@ -1,8 +1,14 @@
 package util
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
 	"reflect"
+	"strings"
 
+	"github.com/alexeldeib/upbound/pkg/logging"
 	"github.com/alexeldeib/upbound/pkg/types"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,51 +23,174 @@ func CheckTitle(vs []*types.ApplicationMetadata, title string) bool {
 	return false
 }
 
+// FindTitle returns the index of the application with the given title, or -1 if none exists.
+func FindTitle(vs []*types.ApplicationMetadata, title string) int {
+	for i, v := range vs {
+		if v.Title == title {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewID returns a random 128-bit identifier encoded as hex.
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails if the OS entropy source is broken, nothing sane to do here.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Results of comparing one field of a query against an application.
+const (
+	Matched    = "matched"
+	Skipped    = "skipped" // The field was empty in the query, so anything matches.
+	Mismatched = "mismatched"
+)
+
+// Explanation is how an application compared against a query, field by field.
+type Explanation struct {
+	Title   string        `yaml:"title"`
+	Matched bool          `yaml:"matched"`
+	Fields  []FieldResult `yaml:"fields"`
+}
+
+// FieldResult is how one field compared. Values are only given when they mismatched.
+type FieldResult struct {
+	Field       string             `yaml:"field"`
+	Result      string             `yaml:"result"`
+	Query       interface{}        `yaml:"query,omitempty"`
+	Known       interface{}        `yaml:"known,omitempty"`
+	Maintainers []MaintainerSearch `yaml:"maintainers,omitempty"` // How each queried maintainer was looked for.
+}
+
+// MaintainerSearch is how one queried maintainer was looked for among an application's maintainers.
+type MaintainerSearch struct {
+	Query   *types.Maintainer  `yaml:"query"`
+	Matched bool               `yaml:"matched"`
+	Tried   []MaintainerResult `yaml:"tried"` // Maintainers compared until one matched, in order.
+}
+
+// MaintainerResult is how one known maintainer compared against a queried one.
+type MaintainerResult struct {
+	Known   *types.Maintainer `yaml:"known"`
+	Matched bool              `yaml:"matched"`
+	Fields  []FieldResult     `yaml:"fields"`
+}
+
 // Compare checks equality between an existing application and a search query, ignoring null values in the desired query.
-func Compare(known *types.ApplicationMetadata, desired *types.ApplicationMetadata) bool {
+func Compare(ctx context.Context, known *types.ApplicationMetadata, desired *types.ApplicationMetadata) bool {
+	return compare(ctx, known, desired, nil)
+}
+
+// Explain compares like Compare, but carries on past the first mismatch to report on every field.
+func Explain(ctx context.Context, known *types.ApplicationMetadata, desired *types.ApplicationMetadata) *Explanation {
+	explanation := &Explanation{Title: known.Title, Fields: make([]FieldResult, 0)}
+	explanation.Matched = compare(ctx, known, desired, explanation)
+	return explanation
+}
+
+// compare implements Compare, recording each field's result into explanation unless it is nil.
+func compare(ctx context.Context, known *types.ApplicationMetadata, desired *types.ApplicationMetadata, explanation *Explanation) bool {
+	logger := logging.FromContext(ctx)
 	// Painful, unsure of a better way to execute this.
 	// On reflection (no pun intended), could statically declare an array of key values to check? Not much improvement.
 	knownVal := reflect.ValueOf(known).Elem()
 	desiredVal := reflect.ValueOf(desired).Elem()
 	numFields := knownVal.NumField()
+	matched := true
 
 	// Iterate the values of the reflected fields, ignoring null but failing immediately on unequal fields.
 	for i := 0; i < numFields; i++ {
 		// Useful debug for edge cases, extraneous for any real use case.
-		log.WithFields(log.Fields{"knownField": knownVal.Field(i).Interface()}).Debug("Known")
-		log.WithFields(log.Fields{"desiredField": desiredVal.Field(i).Interface()}).Debug("Desired")
-		log.WithFields(log.Fields{"equality": !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()), "nullity": !reflect.DeepEqual(desiredVal.Field(i).Interface(), reflect.Zero(desiredVal.Type().Field(i).Type))}).Debug("Result of attempt")
+		field := knownVal.Type().Field(i).Name
+		logger.WithFields(log.Fields{"field": field, "knownField": knownVal.Field(i).Interface()}).Debug("Known")
+		logger.WithFields(log.Fields{"field": field, "desiredField": desiredVal.Field(i).Interface()}).Debug("Desired")
+		logger.WithFields(log.Fields{"field": field, "equality": !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()), "nullity": !reflect.DeepEqual(desiredVal.Field(i).Interface(), reflect.Zero(desiredVal.Type().Field(i).Type))}).Debug("Result of attempt")
 
+		result := FieldResult{Field: strings.ToLower(field), Result: Matched}
 		// We want to check equality BUT ignore the field if it wasn't in the user input.
-		if !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()) {
+		if desiredVal.Field(i).Len() == 0 {
+			result.Result = Skipped
+		} else if !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()) {
 			// If the fields weren't equal, either check for null/zero val or dive into the maintainers array.
 			switch desiredVal.Field(i).Interface().(type) {
 			case []*types.Maintainer:
 				knownMaintainers := knownVal.Field(i).Interface().([]*types.Maintainer)
 				desiredMaintainers := desiredVal.Field(i).Interface().([]*types.Maintainer)
 
-				// If any desired maintainer doesn't have a known counterpart, immediately bail out.
+				// If any desired maintainer doesn't have a known counterpart, the field mismatches.
 				for _, desiredMaintainer := range desiredMaintainers {
-					if !Any(knownMaintainers, desiredMaintainer, CompareMaintainer) {
-						return false
+					search := MaintainerSearch{Query: desiredMaintainer, Tried: make([]MaintainerResult, 0)}
+					try := CompareMaintainer
+					if explanation != nil {
+						try = func(ctx context.Context, known *types.Maintainer, desired *types.Maintainer) bool {
+							tried := MaintainerResult{Known: known, Fields: make([]FieldResult, 0)}
+							tried.Matched = compareMaintainer(ctx, known, desired, &tried.Fields)
+							search.Tried = append(search.Tried, tried)
+							return tried.Matched
+						}
+					}
+					search.Matched = Any(ctx, knownMaintainers, desiredMaintainer, try)
+					result.Maintainers = append(result.Maintainers, search)
+					if !search.Matched {
+						result.Result = Mismatched
+						if explanation == nil {
+							break
+						}
 					}
 				}
 			default:
-				// Everything but the maintainers field is a string, which has zero value of ""
-				if desiredVal.Field(i).Interface() != "" {
-					return false
-				}
+				// Everything but the maintainers field is a string, and empty ones were skipped above.
+				result.Result = Mismatched
+				result.Query = desiredVal.Field(i).Interface()
+				result.Known = knownVal.Field(i).Interface()
 			}
 		}
+		if explanation != nil {
+			explanation.Fields = append(explanation.Fields, result)
+		}
+		if result.Result == Mismatched {
+			matched = false
+			if explanation == nil {
+				return false
+			}
+		}
+	}
+	return matched
+}
+
+// Merge overwrites fields of dst with every non-zero field of patch.
+func Merge(dst *types.ApplicationMetadata, patch *types.ApplicationMetadata) {
+	dstVal := reflect.ValueOf(dst).Elem()
+	patchVal := reflect.ValueOf(patch).Elem()
+	for i := 0; i < patchVal.NumField(); i++ {
+		field := patchVal.Field(i)
+		// Slices are only zero when nil, so an explicitly empty maintainers list still overwrites.
+		if !reflect.DeepEqual(field.Interface(), reflect.Zero(field.Type()).Interface()) {
+			dstVal.Field(i).Set(field)
+		}
 	}
-	return true
 }
 
 // Filter removes elements which are unequal after ignoring null values.
-func Filter(knowns []*types.ApplicationMetadata, desired *types.ApplicationMetadata, f func(*types.ApplicationMetadata, *types.ApplicationMetadata) bool) []*types.ApplicationMetadata {
+func Filter(ctx context.Context, knowns []*types.ApplicationMetadata, desired *types.ApplicationMetadata, f func(context.Context, *types.ApplicationMetadata, *types.ApplicationMetadata) bool) []*types.ApplicationMetadata {
 	filtered := make([]*types.ApplicationMetadata, 0)
+	logger := logging.FromContext(ctx)
+	debug := logger.Logger.IsLevelEnabled(log.DebugLevel)
 	for _, v := range knowns {
-		if f(v, desired) {
+		candidate := ctx
+		if debug {
+			// Tag each candidate's comparison lines, so a trace can be read per application.
+			candidate = logging.NewContext(ctx, logger.WithFields(log.Fields{"candidate": v.Title}))
+		}
+		matched := f(candidate, v, desired)
+		if debug {
+			logging.FromContext(candidate).WithFields(log.Fields{"matched": matched}).Debug("Compared candidate")
+		}
+		if matched {
 			filtered = append(filtered, v)
 		}
 	}
@@ -69,9 +198,9 @@ func Filter(knowns []*types.ApplicationMetadata, desired *types.ApplicationMetad
 }
 
 // Any returns true if the provided maintainer is known to us.
-func Any(knowns []*types.Maintainer, desired *types.Maintainer, f func(*types.Maintainer, *types.Maintainer) bool) bool {
+func Any(ctx context.Context, knowns []*types.Maintainer, desired *types.Maintainer, f func(context.Context, *types.Maintainer, *types.Maintainer) bool) bool {
 	for _, v := range knowns {
-		if f(v, desired) {
+		if f(ctx, v, desired) {
 			return true
 		}
 	}
@@ -81,20 +210,117 @@ func Any(knowns []*types.Maintainer, desired *types.Maintainer, f func(*types.Ma
 // CompareMaintainer returns true if both email and name match a known author, counting comparisons against empty values as true.
 // This naming is also atrocious...
 // Further steps: rename both compare functions for clarity, or attach them as class methods to respective types.
-func CompareMaintainer(known *types.Maintainer, desired *types.Maintainer) bool {
+func CompareMaintainer(ctx context.Context, known *types.Maintainer, desired *types.Maintainer) bool {
+	return compareMaintainer(ctx, known, desired, nil)
+}
+
+// compareMaintainer implements CompareMaintainer, recording each field's result into results unless it is nil.
+func compareMaintainer(ctx context.Context, known *types.Maintainer, desired *types.Maintainer, results *[]FieldResult) bool {
+	logger := logging.FromContext(ctx)
 	knownVal := reflect.ValueOf(known).Elem()
 	desiredVal := reflect.ValueOf(desired).Elem()
 	fields := knownVal.NumField()
+	matched := true
 
 	for i := 0; i < fields; i++ {
-		log.WithFields(log.Fields{"knownField": knownVal.Field(i).Interface()}).Debug("Known")
-		log.WithFields(log.Fields{"desiredField": desiredVal.Field(i).Interface()}).Debug("Desired")
-		log.WithFields(log.Fields{"equality": reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()), "nullity": desiredVal.Field(i).Interface() != nil}).Debug("Result of attempt")
+		field := "Maintainers." + knownVal.Type().Field(i).Name
+		logger.WithFields(log.Fields{"field": field, "knownField": knownVal.Field(i).Interface()}).Debug("Known")
+		logger.WithFields(log.Fields{"field": field, "desiredField": desiredVal.Field(i).Interface()}).Debug("Desired")
+		logger.WithFields(log.Fields{"field": field, "equality": reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()), "nullity": desiredVal.Field(i).Interface() != nil}).Debug("Result of attempt")
+
+		result := FieldResult{Field: strings.ToLower(knownVal.Type().Field(i).Name), Result: Matched}
+		if desiredVal.Field(i).Interface() == "" {
+			result.Result = Skipped
+		} else if !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()) {
+			result.Result = Mismatched
+			result.Query = desiredVal.Field(i).Interface()
+			result.Known = knownVal.Field(i).Interface()
+		}
+		if results != nil {
+			*results = append(*results, result)
+		}
+		// Unlike Compare for ApplicationMetadata, we should shortcircuit here, unless explaining.
+		if result.Result == Mismatched {
+			matched = false
+			if results == nil {
+				return false
+			}
+		}
+	}
+	return matched
+}
 
-		// Unlike Compare for ApplicationMetadata, we should shortcircuit here.
-		if !reflect.DeepEqual(knownVal.Field(i).Interface(), desiredVal.Field(i).Interface()) && desiredVal.Field(i).Interface() != "" {
-			return false
+// Nearest returns the candidate closest to word by edit distance, or "" if none is close enough to be a likely typo.
+func Nearest(word string, candidates []string) string {
+	best, bestDistance := "", len(word)/3+2
+	for _, c := range candidates {
+		if d := Levenshtein(strings.ToLower(word), strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
 		}
 	}
-	return true
+	return best
+}
+
+// Levenshtein counts the single character insertions, deletions and substitutions needed to turn a into b.
+func Levenshtein(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur := make([]int, len(t)+1)
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(t)]
+}
+
+// StatusRecorder remembers the status code and body size written through it, for middleware which reports on responses.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int64
+	wroteHeader bool
+}
+
+// NewStatusRecorder wraps w, assuming 200 until a status is written.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader records the first status written.
+func (s *StatusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.Status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *StatusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	n, err := s.ResponseWriter.Write(b)
+	s.Bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes through, so streaming responses still stream.
+func (s *StatusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		s.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (s *StatusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
 }
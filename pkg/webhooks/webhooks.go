@@ -0,0 +1,403 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alexeldeib/upbound/pkg/logging"
+	"github.com/alexeldeib/upbound/pkg/tracing"
+	"github.com/alexeldeib/upbound/pkg/types"
+	"github.com/alexeldeib/upbound/pkg/util"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Lifecycle events a subscription may listen for.
+const (
+	Created = "application.created"
+	Updated = "application.updated"
+	Deleted = "application.deleted"
+)
+
+// Headers attached to every delivery so receivers can route and verify payloads.
+const (
+	SignatureHeader = "X-Upbound-Signature"
+	EventHeader     = "X-Upbound-Event"
+	DeliveryHeader  = "X-Upbound-Delivery"
+)
+
+// maxHistory caps the number of delivery attempts we remember, oldest are dropped first.
+const maxHistory = 1000
+
+// watchBuffer is how many events a watcher may fall behind before it is dropped.
+const watchBuffer = 64
+
+// Subscription registers a URL to receive signed event payloads.
+type Subscription struct {
+	ID     string   `yaml:"id,omitempty"`
+	URL    string   `yaml:"url" validate:"required,url"`
+	Secret string   `yaml:"secret,omitempty" validate:"required"`
+	Events []string `yaml:"events,omitempty" validate:"dive,oneof=application.created application.updated application.deleted"`
+}
+
+// Wants returns true if the subscription listens for the given event type. No events means all events.
+func (s *Subscription) Wants(eventType string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the payload POSTed to subscribers.
+type Event struct {
+	ID          string                     `yaml:"id"`
+	Type        string                     `yaml:"type"`
+	Time        time.Time                  `yaml:"time"`
+	Application *types.ApplicationMetadata `yaml:"application"`
+}
+
+// Delivery records a single attempt to deliver an event to a subscription.
+type Delivery struct {
+	SubscriptionID string    `yaml:"subscriptionId"`
+	EventID        string    `yaml:"eventId"`
+	EventType      string    `yaml:"eventType"`
+	URL            string    `yaml:"url"`
+	Attempt        int       `yaml:"attempt"`
+	StatusCode     int       `yaml:"statusCode,omitempty"`
+	Error          string    `yaml:"error,omitempty"`
+	Succeeded      bool      `yaml:"succeeded"`
+	Time           time.Time `yaml:"time"`
+}
+
+// DeadLetter holds an event which exhausted all retries against a subscription.
+type DeadLetter struct {
+	SubscriptionID string    `yaml:"subscriptionId"`
+	URL            string    `yaml:"url"`
+	Event          *Event    `yaml:"event"`
+	Attempts       int       `yaml:"attempts"`
+	LastError      string    `yaml:"lastError"`
+	Time           time.Time `yaml:"time"`
+}
+
+// Dispatcher fans events out to subscriptions, retrying failed deliveries with exponential backoff.
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration // Delay before the first retry, doubled on each subsequent attempt.
+
+	mu            sync.RWMutex
+	subscriptions []*Subscription
+	history       []*Delivery
+	deadLetters   []*DeadLetter
+	wg            sync.WaitGroup
+	closed        bool
+	closing       chan struct{} // Closed by Close to cut retry backoffs short.
+
+	watchMu      sync.Mutex
+	watchers     map[*watcher]struct{}
+	watchesEnded bool
+}
+
+// watcher receives the events of one application, or of every application when title is empty.
+type watcher struct {
+	title  string
+	events chan *Event
+}
+
+// NewDispatcher returns a dispatcher with sane defaults for retries and timeouts.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     time.Second,
+		closing:     make(chan struct{}),
+	}
+}
+
+// Subscribe registers a subscription, assigning it a fresh ID.
+func (d *Dispatcher) Subscribe(sub *Subscription) *Subscription {
+	sub.ID = util.NewID()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, sub)
+	return sub
+}
+
+// Unsubscribe removes a subscription, returning false if it did not exist.
+func (d *Dispatcher) Unsubscribe(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, sub := range d.subscriptions {
+		if sub.ID == id {
+			d.subscriptions = append(d.subscriptions[:i], d.subscriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Subscriptions lists registered subscriptions with their secrets redacted.
+func (d *Dispatcher) Subscriptions() []*Subscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	subs := make([]*Subscription, 0, len(d.subscriptions))
+	for _, sub := range d.subscriptions {
+		redacted := *sub
+		redacted.Secret = ""
+		subs = append(subs, &redacted)
+	}
+	return subs
+}
+
+// History returns recorded delivery attempts, optionally restricted to one subscription.
+func (d *Dispatcher) History(subscriptionID string) []*Delivery {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	deliveries := make([]*Delivery, 0)
+	for _, delivery := range d.history {
+		if subscriptionID == "" || delivery.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// DeadLetters returns events which could not be delivered after all retries.
+func (d *Dispatcher) DeadLetters() []*DeadLetter {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]*DeadLetter{}, d.deadLetters...)
+}
+
+// Dispatch asynchronously delivers an event for the application to every interested subscription, and hands it to
+// watchers of the application. Deliveries continue the trace in ctx, but outlive its cancellation.
+func (d *Dispatcher) Dispatch(ctx context.Context, eventType string, app *types.ApplicationMetadata) {
+	ctx = trace.ContextWithSpanContext(logging.Detach(ctx), trace.SpanContextFromContext(ctx))
+	event := &Event{ID: util.NewID(), Type: eventType, Time: time.Now().UTC(), Application: app}
+	// Marshal up front so later changes to the application can't leak into the payload.
+	body, err := yaml.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).WithFields(log.Fields{"event": eventType, "error": err}).Error("Failed to marshal webhook event")
+		return
+	}
+	d.notify(event)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		logging.FromContext(ctx).WithFields(log.Fields{"event": eventType, "title": app.Title}).Warn("Dropped webhook event dispatched after close")
+		return
+	}
+	for _, sub := range d.subscriptions {
+		if sub.Wants(eventType) {
+			d.wg.Add(1)
+			go d.deliver(ctx, *sub, event, body)
+		}
+	}
+}
+
+// Wait blocks until all in-flight deliveries, including retries, have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Check is a health check failing once the dispatcher is closed.
+func (d *Dispatcher) Check(ctx context.Context) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return errors.New("dispatcher is closed")
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for in-flight deliveries until the context is done. Deliveries waiting to
+// retry are dead-lettered rather than retried, so shutdown isn't held up by an unreachable receiver.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.EndWatches()
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.closing)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Watch returns a channel receiving the events of the application with the title, or of every application when the
+// title is empty. The channel is closed once ctx is done, the watcher falls more than watchBuffer events behind, or
+// watches are ended, after which callers should watch again if they still care.
+func (d *Dispatcher) Watch(ctx context.Context, title string) <-chan *Event {
+	w := &watcher{title: title, events: make(chan *Event, watchBuffer)}
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	if d.watchesEnded {
+		close(w.events)
+		return w.events
+	}
+	if d.watchers == nil {
+		d.watchers = make(map[*watcher]struct{})
+	}
+	d.watchers[w] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		d.unwatch(w)
+	}()
+	return w.events
+}
+
+// EndWatches closes every watch and refuses new ones, so open streams don't hold up shutting down the HTTP server.
+func (d *Dispatcher) EndWatches() {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	d.watchesEnded = true
+	for w := range d.watchers {
+		delete(d.watchers, w)
+		close(w.events)
+	}
+}
+
+func (d *Dispatcher) unwatch(w *watcher) {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	if _, ok := d.watchers[w]; ok {
+		delete(d.watchers, w)
+		close(w.events)
+	}
+}
+
+// notify hands the event to its watchers without blocking, dropping those too far behind to take it.
+func (d *Dispatcher) notify(event *Event) {
+	d.watchMu.Lock()
+	defer d.watchMu.Unlock()
+	for w := range d.watchers {
+		if w.title != "" && w.title != event.Application.Title {
+			continue
+		}
+		select {
+		case w.events <- event:
+		default:
+			delete(d.watchers, w)
+			close(w.events)
+		}
+	}
+}
+
+// deliver POSTs the payload until it succeeds or runs out of attempts, then dead-letters it.
+func (d *Dispatcher) deliver(ctx context.Context, sub Subscription, event *Event, body []byte) {
+	defer d.wg.Done()
+	attempts, lastErr, ok := d.attempt(ctx, sub, event, body)
+	if ok {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadLetters = append(d.deadLetters, &DeadLetter{SubscriptionID: sub.ID, URL: sub.URL, Event: event, Attempts: attempts, LastError: lastErr, Time: time.Now().UTC()})
+}
+
+// attempt retries delivery with exponential backoff, giving up early if the dispatcher is closed while waiting.
+// It returns the number of attempts made, the last error, and whether delivery succeeded.
+func (d *Dispatcher) attempt(ctx context.Context, sub Subscription, event *Event, body []byte) (int, string, bool) {
+	backoff := d.Backoff
+	var lastErr string
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-d.closing:
+				return attempt - 1, "dispatcher closed before retrying, last error: " + lastErr, false
+			}
+		}
+		delivery := &Delivery{SubscriptionID: sub.ID, EventID: event.ID, EventType: event.Type, URL: sub.URL, Attempt: attempt, Time: time.Now().UTC()}
+		status, err := d.send(ctx, sub, event, body, attempt)
+		delivery.StatusCode = status
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Succeeded = true
+		}
+		d.record(delivery)
+		if delivery.Succeeded {
+			return attempt, "", true
+		}
+		lastErr = delivery.Error
+		logging.FromContext(ctx).WithFields(log.Fields{"subscription": sub.ID, "event": event.Type, "attempt": attempt, "error": lastErr}).Info("Webhook delivery failed")
+	}
+	return d.MaxAttempts, lastErr, false
+}
+
+// send performs a single signed delivery attempt, treating any non-2xx response as failure.
+func (d *Dispatcher) send(ctx context.Context, sub Subscription, event *Event, body []byte, attempt int) (status int, err error) {
+	ctx, span := tracing.Start(ctx, "webhook.deliver", attribute.String("event", event.Type), attribute.String("subscription", sub.ID), attribute.Int("attempt", attempt))
+	defer func() {
+		span.SetAttributes(attribute.Int("status", status))
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	tracing.Inject(ctx, req.Header)
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(delivery *Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.history = append(d.history, delivery)
+	if len(d.history) > maxHistory {
+		d.history = d.history[len(d.history)-maxHistory:]
+	}
+}
+
+// Sign computes the signature header value for a payload, receivers should compare it using hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}